@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/dotdak/go-templater/generator"
+	"github.com/peterbourgon/ff/v3/ffcli"
+)
+
+var (
+	checkCmd = &ffcli.Command{
+		Name:       "check",
+		ShortUsage: "gotem check [gen flags] [-json]",
+		ShortHelp:  "Fail when generated files are out of date",
+		LongHelp: "Runs gen in memory with the same flags and exits non-zero when any\n" +
+			"file would be created or updated. Generated files are compared as a\n" +
+			"whole while scaffolded ones, which are safe to edit, are only checked\n" +
+			"for missing methods unless -force, whatever -overwrite says. With\n" +
+			"-merge every file is only checked for missing methods.",
+		FlagSet: checkFlagSet,
+		Options: genOptions,
+		Exec:    check,
+	}
+
+	checkFlagSet = func() *flag.FlagSet {
+		fs := genFlags(newFlagSet("check"))
+		fs.BoolVar(&checkArgs.json, "json", false, "print the report as JSON")
+		return fs
+	}()
+
+	checkArgs struct {
+		json bool
+	}
+)
+
+type checkReport struct {
+	UpToDate bool        `json:"up_to_date"`
+	Files    []checkFile `json:"files"`
+}
+
+type checkFile struct {
+	Path   string `json:"path"`
+	Status string `json:"status"`
+	Diff   string `json:"diff,omitempty"`
+}
+
+func check(ctx context.Context, args []string) error {
+	if checkArgs.json {
+		// keep stdout parseable
+		WarnLog.SetOutput(os.Stderr)
+	}
+
+	report := checkReport{UpToDate: true}
+	err := eachJob(checkFlagSet, func() error {
+		in, err := input()
+		if err != nil {
+			return err
+		}
+		opts := generatorOptions(in)
+		// a job leaving existing files alone would always be up to date
+		opts.Overwrite = !opts.Merge
+		opts.DryRun = true
+		res, err := generator.Generate(ctx, opts)
+		if err != nil {
+			return err
+		}
+
+		for _, c := range res.Changes {
+			f := checkFile{Path: c.RelName(), Status: c.Status(), Diff: c.Diff()}
+			report.UpToDate = report.UpToDate && f.Status == "unchanged"
+			report.Files = append(report.Files, f)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(report.Files, func(i, j int) bool {
+		return report.Files[i].Path < report.Files[j].Path
+	})
+
+	if checkArgs.json {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else if err := printReport(os.Stdout, report); err != nil {
+		return err
+	}
+
+	if !report.UpToDate {
+		return ExitFailure
+	}
+	return nil
+}
+
+func printReport(w io.Writer, report checkReport) error {
+	var outdated int
+	for _, f := range report.Files {
+		switch f.Status {
+		case "unchanged":
+			fmt.Fprintf(w, "ok       %s\n", f.Path)
+		case "created":
+			outdated++
+			fmt.Fprintf(w, "missing  %s\n", f.Path)
+		default:
+			outdated++
+			fmt.Fprintf(w, "outdated %s\n", f.Path)
+		}
+	}
+	for _, f := range report.Files {
+		io.WriteString(w, f.Diff)
+	}
+
+	if outdated > 0 {
+		_, err := fmt.Fprintf(w, "%d of %d generated files out of date, run gotem gen\n", outdated, len(report.Files))
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%d generated files up to date\n", len(report.Files))
+	return err
+}
@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dotdak/go-templater/generator"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+)
+
+var (
+	cleanCmd = &ffcli.Command{
+		Name:       "clean",
+		ShortUsage: "gotem clean [gen flags]",
+		ShortHelp:  "Remove the generated files of services that no longer exist",
+		LongHelp: "Runs gen in memory with the same flags and removes the files of the\n" +
+			"manifest, -lock, that it no longer generates. Only the files generated\n" +
+			"into the same directories from the same input, or from an input since\n" +
+			"deleted, are removed: those of other inputs and jobs are kept. Files\n" +
+			"edited by hand are kept unless -force is set, -dry-run only prints what\n" +
+			"would be removed.",
+		FlagSet: cleanFlagSet,
+		Options: genOptions,
+		Exec:    clean,
+	}
+
+	cleanFlagSet = genFlags(newFlagSet("clean"))
+)
+
+// lockRuns is what the runs sharing a manifest generated, clean only
+// removes the files of the sources and directories they cover.
+type lockRuns struct {
+	// keep holds the files still generated.
+	keep    map[string]bool
+	inputs  map[string]bool
+	sources map[string]bool
+	dirs    map[string]bool
+}
+
+// owns reports whether f, at fileName in the manifest of dir, is a file of
+// the runs: generated into one of their directories from an input they
+// read, or from a source they read or whose path is gone.
+func (r *lockRuns) owns(dir, fileName string, f *lockFile) bool {
+	if !r.dirs[filepath.Dir(fileName)] {
+		return false
+	}
+	switch {
+	case r.inputs[f.Input], f.Source != "" && r.sources[f.Source]:
+		return true
+	case f.Source == "" || strings.Contains(f.Source, "@"):
+		return false
+	}
+	_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(strings.TrimSuffix(f.Source, "/..."))))
+	return errors.Is(err, fs.ErrNotExist)
+}
+
+func clean(ctx context.Context, args []string) error {
+	var names []string
+	runs := make(map[string]*lockRuns)
+	err := eachJob(cleanFlagSet, func() error {
+		if genArgs.lock == "" {
+			return nil
+		}
+		res, err := run(ctx, nil, true)
+		if err != nil {
+			return err
+		}
+
+		name, err := filepath.Abs(genArgs.lock)
+		if err != nil {
+			return err
+		}
+		r := runs[name]
+		if r == nil {
+			r = &lockRuns{
+				keep:    make(map[string]bool),
+				inputs:  make(map[string]bool),
+				sources: make(map[string]bool),
+				dirs:    make(map[string]bool),
+			}
+			runs[name] = r
+			names = append(names, genArgs.lock)
+		}
+		for _, c := range res.Changes {
+			r.keep[c.Name] = true
+		}
+		for _, in := range res.Inputs {
+			r.inputs[in] = true
+		}
+		for _, dir := range res.Dirs {
+			r.dirs[dir] = true
+		}
+		if res.Source != "" {
+			r.sources[res.Source] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		abs, err := filepath.Abs(name)
+		if err != nil {
+			return err
+		}
+		if err := cleanLock(name, runs[abs]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cleanLock removes the files of the manifest called name that the runs
+// own but no longer generate. The files of other sources, jobs and
+// directories are left alone.
+func cleanLock(name string, runs *lockRuns) error {
+	m, files, err := readLock(name)
+	if err != nil {
+		return err
+	}
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return err
+	}
+
+	var removed int
+	for _, f := range files {
+		fileName, err := filepath.Abs(f.name)
+		if err != nil {
+			return err
+		}
+		if runs.keep[fileName] || !runs.owns(filepath.Dir(abs), fileName, f) {
+			continue
+		}
+		if f.state == "edited" && !genArgs.force {
+			WarnLog.Printf("%s: edited by hand, rerun with -force to remove it", f.name)
+			continue
+		}
+
+		m.Remove(f.Path)
+		removed++
+		if genArgs.dryRun {
+			fmt.Printf("would remove %s\n", f.name)
+			continue
+		}
+		if f.state != "missing" {
+			if err := os.Remove(f.name); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("removed %s\n", f.name)
+	}
+	if genArgs.dryRun || removed == 0 {
+		return nil
+	}
+
+	b, err := m.Encode()
+	if err != nil {
+		return err
+	}
+	return generator.Disk{}.WriteFile(abs, b)
+}
@@ -0,0 +1,84 @@
+package cli
+
+import "testing"
+
+var (
+	sFiles = []string{"handlers/v1/s_handler.go", "services/s_service.go", "services/s_service_impl.go"}
+	dFiles = []string{"handlers/v1/d_handler.go", "services/d_service.go", "services/d_service_impl.go"}
+)
+
+func TestCleanInputs(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	writeFiles(t, dir, map[string]string{
+		"go.mod":  "module example.com/cl\n\ngo 1.22\n",
+		"s.proto": service("s", "SService"),
+		"d.proto": service("d", "DService"),
+	})
+	gotem(t, genCmd, "-proto", "s.proto")
+	gotem(t, genCmd, "-proto", "d.proto")
+
+	// the files of s.proto aren't generated from d.proto
+	gotem(t, cleanCmd, "-proto", "d.proto")
+	exist(t, dir, true, sFiles...)
+	exist(t, dir, true, dFiles...)
+
+	writeFiles(t, dir, map[string]string{"d.proto": service("d", "")})
+	gotem(t, cleanCmd, "-proto", "d.proto", "-dry-run")
+	exist(t, dir, true, dFiles...)
+	gotem(t, cleanCmd, "-proto", "d.proto")
+	exist(t, dir, true, sFiles...)
+	exist(t, dir, false, dFiles...)
+}
+
+func TestCleanDeletedInput(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	writeFiles(t, dir, map[string]string{
+		"go.mod":  "module example.com/cl\n\ngo 1.22\n",
+		"s.proto": service("s", "SService"),
+		"d.proto": service("d", "DService"),
+	})
+	gotem(t, genCmd, "-proto", "s.proto")
+	gotem(t, genCmd, "-proto", "d.proto")
+
+	if err := removeFile(dir, "d.proto"); err != nil {
+		t.Fatal(err)
+	}
+	gotem(t, cleanCmd, "-proto", "s.proto")
+	exist(t, dir, true, sFiles...)
+	exist(t, dir, false, dFiles...)
+}
+
+func TestCleanJobs(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	writeFiles(t, dir, map[string]string{
+		"go.mod":  "module example.com/cl\n\ngo 1.22\n",
+		"s.proto": service("s", "SService"),
+		"d.proto": service("d", "DService"),
+		".gotem.yaml": `jobs:
+  - name: foo
+    proto: s.proto
+    out: ./foo/handlers
+    subdomain-out: ./foo/services
+  - name: bar
+    proto: d.proto
+    out: ./bar/handlers
+    subdomain-out: ./bar/services
+`,
+	})
+	gotem(t, genCmd)
+	foo := []string{"foo/handlers/s_handler.go", "foo/services/s_service.go", "foo/services/s_service_impl.go"}
+	bar := []string{"bar/handlers/d_handler.go", "bar/services/d_service.go", "bar/services/d_service_impl.go"}
+
+	// bar isn't run, its files stay even once its service is gone
+	writeFiles(t, dir, map[string]string{"d.proto": service("d", "")})
+	gotem(t, cleanCmd, "-job", "foo")
+	exist(t, dir, true, foo...)
+	exist(t, dir, true, bar...)
+
+	gotem(t, cleanCmd)
+	exist(t, dir, true, foo...)
+	exist(t, dir, false, bar...)
+}
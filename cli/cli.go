@@ -5,6 +5,8 @@ import (
 	"flag"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/peterbourgon/ff/v3/ffcli"
 )
@@ -26,8 +28,13 @@ func Run(args []string) error {
 		Subcommands: []*ffcli.Command{
 			versionCmd,
 			genCmd,
+			checkCmd,
+			inspectCmd,
+			statusCmd,
+			cleanCmd,
 		},
 		FlagSet: genCmd.FlagSet,
+		Options: genCmd.Options,
 		Exec:    genCmd.Exec,
 	}
 
@@ -35,5 +42,9 @@ func Run(args []string) error {
 		return err
 	}
 
-	return rootCmd.Run(context.Background())
+	// an interrupted run skips the files not started yet and rolls back
+	// the ones written
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return rootCmd.Run(ctx)
 }
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/peterbourgon/ff/v3"
+	"github.com/peterbourgon/ff/v3/ffcli"
+)
+
+// defaultArgs are the flags as registered, taken by TestMain once the
+// flag sets are. The commands of a test start from them.
+var defaultArgs = genArgs
+
+func TestMain(m *testing.M) {
+	defaultArgs = genArgs
+	os.Exit(m.Run())
+}
+
+// chdir moves into dir for the rest of the test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// gotem runs cmd with args, the flags of the previous commands reset.
+func gotem(t *testing.T, cmd *ffcli.Command, args ...string) {
+	t.Helper()
+	genArgs = defaultArgs
+	if err := ff.Parse(cmd.FlagSet, args, cmd.Options...); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Exec(context.Background(), cmd.FlagSet.Args()); err != nil {
+		t.Fatalf("%s %v: %v", cmd.Name, args, err)
+	}
+}
+
+// writeFiles writes the files of contents into dir, by slash separated
+// name.
+func writeFiles(t *testing.T, dir string, contents map[string]string) {
+	t.Helper()
+	for name, content := range contents {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// service is a .proto file declaring the service called name in package
+// pkg, none when name is empty.
+func service(pkg, name string) string {
+	s := "syntax = \"proto3\";\n" +
+		"package " + pkg + ".v1;\n" +
+		"option go_package = \"example.com/cl/api/" + pkg + "/v1;" + pkg + "v1\";\n"
+	if name != "" {
+		s += "service " + name + " { rpc Get(GetRequest) returns (GetResponse); }\n"
+	}
+	return s + "message GetRequest {}\nmessage GetResponse {}\n"
+}
+
+// exist fails the test unless the files of names exist in dir as want
+// says.
+func exist(t *testing.T, dir string, want bool, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name)))
+		if got := err == nil; got != want {
+			t.Errorf("%s exists: %t, want %t", name, got, want)
+		}
+	}
+}
+
+func removeFile(dir, name string) error {
+	return os.Remove(filepath.Join(dir, filepath.FromSlash(name)))
+}
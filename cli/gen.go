@@ -5,361 +5,223 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"go/ast"
-	"go/parser"
-	"go/token"
-	"io/fs"
+	"io"
 	"os"
-	"path/filepath"
-	"regexp"
 	"strings"
 
-	"github.com/dotdak/go-templater/pkg/module"
-	"github.com/dotdak/go-templater/pkg/shorten"
+	"github.com/dotdak/go-templater/generator"
 
+	"github.com/peterbourgon/ff/v3"
 	"github.com/peterbourgon/ff/v3/ffcli"
-	"github.com/yoheimuta/go-protoparser/v4"
-	proto_parser "github.com/yoheimuta/go-protoparser/v4/parser"
-	"golang.org/x/tools/go/ast/astutil"
-	"golang.org/x/tools/go/packages"
 )
 
 var (
 	ExitFailure = errors.New("exit failure")
-	ErrOddParam = errors.New("missing params or values")
-	ErrNoInput  = errors.New("no input file")
+	ErrOddParam = generator.ErrOddParam
+	ErrNoInput  = generator.ErrNoInput
+	ErrStale    = generator.ErrStale
+	ErrNotOwned = generator.ErrNotOwned
 
 	genCmd = &ffcli.Command{
 		Name:       "gen",
 		ShortUsage: "gotem gen [commands flags]",
 		ShortHelp:  "Generate template files",
-		FlagSet: func() *flag.FlagSet {
-			fs := newFlagSet("gen")
-			fs.StringVar(&genArgs.in, "in", "", "input package directory")
-			fs.StringVar(&genArgs.out, "out", "./handlers/v1", "output directory")
-			fs.StringVar(&genArgs.domain, "domain", "Handler", "specify generated domain")
-			fs.StringVar(&genArgs.subDomain, "subdomain", "Service", "specify generated domain")
-			fs.StringVar(&genArgs.subDomainOut, "subdomain-out", "./services", "specify generated domain")
-			fs.BoolVar(&genArgs.overWrite, "overwrite", true, "overwrite existed generated files")
-			return fs
-		}(),
-		Exec: generate,
+		FlagSet:    genFlagSet,
+		Options:    genOptions,
+		Exec:       generate,
+	}
+
+	genFlagSet = func() *flag.FlagSet {
+		fs := genFlags(newFlagSet("gen"))
+		fs.StringVar(&genArgs.outArchive, "out-archive", "", "write the files to a .zip or .tar.gz archive instead of the disk, - prints them as txtar")
+		return fs
+	}()
+
+	genOptions = []ff.Option{
+		ff.WithConfigFileFlag("config"),
+		ff.WithConfigFileParser(configParser),
+		ff.WithAllowMissingConfigFile(true),
 	}
-	goPath     = os.Getenv("HOME") + "/go"
-	versionReg = regexp.MustCompile("@v[0-9.]+-[0-9a-z]+-[0-9a-z]+")
 
 	genArgs struct {
+		layers       []*generator.Layer
+		jobs         []*job
+		job          string
 		in           string
 		out          string
 		subDomainOut string
 		domain       string
 		subDomain    string
 		overWrite    bool
-		// WIP
-		inProto string
+		merge        bool
+		force        bool
+		stale        string
+		impl         bool
+		dryRun       bool
+		inProto      string
+		inDescriptor string
+		inDescFiles  string
+		inModel      string
+		templates    string
+		outArchive   string
+		lock         string
+		parallel     int
 	}
 )
 
-func load(ctx context.Context, args ...string) ([]*packages.Package, []error) {
-	wd, err := os.Getwd()
-	if err != nil {
-		return nil, []error{err}
-	}
-	cfg := &packages.Config{
-		Context: ctx,
-		Mode:    packages.LoadAllSyntax,
-		Dir:     wd,
-		Env:     os.Environ(),
-	}
-
-	escaped := make([]string, len(args))
-	for i := range args {
-		escaped[i] = "pattern=" + args[i]
-	}
-	pkgs, err := packages.Load(cfg, args...)
-	if err != nil {
-		return nil, []error{err}
-	}
+// genFlags registers the flags shared by the commands running the
+// generator.
+func genFlags(fs *flag.FlagSet) *flag.FlagSet {
+	fs.StringVar(&genArgs.in, "in", "", "input package directory or module@version, may end with /...")
+	fs.StringVar(&genArgs.out, "out", "./handlers/v1", "output directory")
+	fs.StringVar(&genArgs.domain, "domain", "Handler", "specify generated domain")
+	fs.StringVar(&genArgs.subDomain, "subdomain", "Service", "specify generated domain")
+	fs.StringVar(&genArgs.subDomainOut, "subdomain-out", "./services", "specify generated domain")
+	fs.BoolVar(&genArgs.overWrite, "overwrite", true, "replace generated files and add missing methods to scaffolded ones")
+	fs.BoolVar(&genArgs.merge, "merge", false, "add missing methods to existed generated files, keeping the rest")
+	fs.BoolVar(&genArgs.force, "force", false, "let -overwrite and -merge touch files without a gotem header, and -overwrite replace scaffolded ones")
+	fs.BoolVar(&genArgs.dryRun, "dry-run", false, "print what would be created or updated, with diffs, without writing")
+	fs.StringVar(&genArgs.stale, "stale", generator.StaleReport, "what -merge does with methods whose RPC was removed: report, deprecate or move")
+	fs.BoolVar(&genArgs.impl, "impl", true, "also generate a struct implementing each service interface")
+	fs.StringVar(&genArgs.inProto, "proto", "", "input .proto file, used instead of -in")
+	fs.StringVar(&genArgs.inDescriptor, "descriptor-set", "", "input FileDescriptorSet built with imports, used instead of -in")
+	fs.StringVar(&genArgs.inDescFiles, "descriptor-file", "", "comma separated files of -descriptor-set to read, those no other file imports when empty")
+	fs.StringVar(&genArgs.inModel, "model", "", "input model written by gotem inspect, used instead of -in")
+	fs.StringVar(&genArgs.templates, "templates", "", "directory overriding the domain, interface and impl templates")
+	fs.StringVar(&genArgs.lock, "lock", ".gotem.lock", "manifest of the generated files, empty to keep none")
+	fs.IntVar(&genArgs.parallel, "j", 0, "files rendered and written at once, 0 for one per CPU")
+	fs.String("config", ".gotem.yaml", "config file, keys are the flag names")
+	fs.StringVar(&genArgs.job, "job", "", "run only this job of the config file")
+	return fs
+}
 
-	var errs []error
-	for _, p := range pkgs {
-		for _, e := range p.Errors {
-			errs = append(errs, e)
+// input is the source picked by -proto, -descriptor-set, -model or -in.
+func input() (generator.Source, error) {
+	switch {
+	case genArgs.inProto != "":
+		return generator.Proto(genArgs.inProto), nil
+	case genArgs.inDescriptor != "":
+		var files []string
+		if genArgs.inDescFiles != "" {
+			files = strings.Split(genArgs.inDescFiles, ",")
 		}
+		return generator.DescriptorSet(genArgs.inDescriptor, files...), nil
+	case genArgs.inModel != "":
+		return generator.ModelFile(genArgs.inModel), nil
+	case genArgs.in != "":
+		return generator.GoPackage(genArgs.in), nil
+	default:
+		return nil, ErrNoInput
 	}
-
-	if len(errs) > 0 {
-		return nil, errs
-	}
-
-	return pkgs, nil
 }
 
-func logErrors(errs ...error) {
-	for _, e := range errs {
-		fmt.Fprintf(os.Stderr, "%s", e.Error())
+// generatorOptions turns the flags into the options of a run reading
+// from in.
+func generatorOptions(in generator.Source) generator.Options {
+	layers := genArgs.layers
+	if len(layers) == 0 {
+		layers = []*generator.Layer{
+			{Name: genArgs.domain, Out: genArgs.out},
+			{Name: genArgs.subDomain, Out: genArgs.subDomainOut},
+		}
 	}
-}
 
-// WIP
-func readProto() error {
-	absPath, err := filepath.Abs(genArgs.inProto)
-	if err != nil {
-		return err
+	return generator.Options{
+		Input:     in,
+		Layers:    layers,
+		NoImpl:    !genArgs.impl,
+		Templates: genArgs.templates,
+		Overwrite: genArgs.overWrite,
+		Merge:     genArgs.merge,
+		Force:     genArgs.force,
+		Stale:     genArgs.stale,
+		DryRun:    genArgs.dryRun,
+		Manifest:  genArgs.lock,
+		Parallel:  genArgs.parallel,
+		Log:       WarnLog,
 	}
+}
 
-	inputFile, err := os.Open(absPath)
+// run generates from the input flags into sink, the disk when nil, with
+// dry run forced by plan.
+func run(ctx context.Context, sink generator.Sink, plan bool) (*generator.Result, error) {
+	in, err := input()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	opts := generatorOptions(in)
+	opts.Sink = sink
+	opts.DryRun = opts.DryRun || plan
+	return generator.Generate(ctx, opts)
+}
 
-	generator := DomainGenerator{}
-
-	if parts := strings.Split(genArgs.out, "/"); len(parts) > 0 {
-		generator.Package = parts[len(parts)-1]
-	}
-	// if parts := strings.Split(genArgs.in, "/"); len(parts) > 0 {
-	// 	generator.FileName = parts[len(parts)-1]
-	// }
-
-	proto, err := protoparser.Parse(inputFile)
-	if err != nil {
-		return err
+func generate(ctx context.Context, args []string) (err error) {
+	var sink generator.Sink
+	if genArgs.outArchive != "" && !genArgs.dryRun {
+		archive, closeArchive, err := openArchive(genArgs.outArchive)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := closeArchive(); err == nil {
+				err = cerr
+			}
+		}()
+		sink = archive
 	}
 
-	for _, body := range proto.ProtoBody {
-		if pkg, ok := body.(*proto_parser.Option); ok && pkg.OptionName == "go_package" {
-			goPackage := strings.Trim(pkg.Constant, "\"")
-			if parts := strings.Split(goPackage, ";"); len(parts) > 0 {
-				generator.ImportPackage = parts[0]
-			}
-			continue
-		}
-		service, ok := body.(*proto_parser.Service)
-		if !ok {
-			continue
+	return eachJob(genFlagSet, func() error {
+		res, err := run(ctx, sink, false)
+		if res == nil || !genArgs.dryRun {
+			return err
 		}
 
-		for _, serviceBody := range service.ServiceBody {
-			rpc, ok := serviceBody.(*proto_parser.RPC)
-			if !ok {
-				continue
+		for _, c := range res.Changes {
+			if err := printChange(os.Stdout, c); err != nil {
+				return err
 			}
-
-			fmt.Println(rpc.RPCName)
 		}
-	}
-	return nil
-}
-
-func getPackageFromDir(dir string) string {
-	parts := strings.Split(dir, "/")
-	return parts[len(parts)-1]
+		return err
+	})
 }
 
-func generate(ctx context.Context, args []string) error {
-	inAbs, err := filepath.Abs(genArgs.in)
+// openArchive returns the archive every job of the run is written to, its
+// files named relative to the working directory, and the func writing it
+// out.
+func openArchive(name string) (*generator.Archive, func() error, error) {
+	wd, err := os.Getwd()
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	outAbs, err := filepath.Abs(genArgs.out)
-	if err != nil {
-		return err
+	if name == "-" {
+		// stdout carries the archive
+		WarnLog.SetOutput(os.Stderr)
+		archive := generator.NewArchive(os.Stdout, wd, generator.Txtar)
+		return archive, archive.Close, nil
 	}
-	subOutAbs, err := filepath.Abs(genArgs.subDomainOut)
+
+	format, err := generator.ArchiveFormatOf(name)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	fset := token.NewFileSet()
-	packages, err := parser.ParseDir(fset, inAbs, func(fi fs.FileInfo) bool {
-		return strings.HasSuffix(fi.Name(), "_grpc.pb.go")
-	}, parser.ParseComments)
+	f, err := os.Create(name)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	const preAlloc = 5
-	domainFiles := make([]*DomainGenerator, 0, preAlloc)
-	intFiles := make(map[string]*IntGen)
-	for pkgName, v := range packages {
-		for fileName, fi := range v.Files {
-			pkgPath := inAbs
-			if strings.HasPrefix(inAbs, goPath+"/pkg/mod") {
-				pkgPath = strings.TrimPrefix(pkgPath, goPath+"/pkg/mod/")
-			} else {
-				pkgPath = "github.com" + inAbs
-			}
-
-			pkgPath = versionReg.ReplaceAllString(pkgPath, "")
-			pkgPath, err = module.DecodePath(pkgPath)
-			if err != nil {
-				ErrLog.Println(err)
-				continue
-			}
-			parts := strings.Split(fileName, "/")
-			domainFile := &DomainGenerator{
-				FileName: fmt.Sprintf(
-					"%s/%s_%s.go",
-					outAbs,
-					shorten.TrimFileName(parts[len(parts)-1]),
-					strings.ToLower(genArgs.domain),
-				),
-				Package: getPackageFromDir(outAbs),
-				Imports: []*Import{
-					{Path: "context"},
-					{Path: pkgPath},
-					{Name: shorten.Lookup(genArgs.subDomain), Path: "github.com/" + subOutAbs[strings.Index(subOutAbs, "go-templater"):]},
-				},
-				ServicePackage: pkgName,
-				Domain:         genArgs.domain,
-			}
-			intFile := &IntGen{
-				FileName: fmt.Sprintf(
-					"%s/%s_%s.go",
-					subOutAbs,
-					shorten.TrimFileName(parts[len(parts)-1]),
-					strings.ToLower(genArgs.subDomain),
-				),
-				Package: getPackageFromDir(subOutAbs),
-				Domain:  genArgs.subDomain,
-				Imports: []*Import{
-					{Path: "context"},
-					{Path: pkgPath},
-				},
-			}
-			astutil.Apply(fi, nil, func(c *astutil.Cursor) bool {
-				switch x := c.Node().(type) {
-				case *ast.TypeSpec:
-					y, ok := x.Type.(*ast.InterfaceType)
-					if !ok {
-						return true
-					}
-					intName := x.Name.Name
-					if !strings.HasSuffix(intName, "Server") ||
-						strings.HasPrefix(intName, "Unimplemented") ||
-						strings.HasPrefix(intName, "Unsafe") {
-						return true
-					}
-					intName = strings.TrimSuffix(intName, "ServiceServer")
-					subDomainName := intName + genArgs.subDomain
-					domainBody := &DomainBody{
-						ServiceName: intName,
-						Injectors: []*Injector{{
-							Name:    subDomainName,
-							Alias:   shorten.LowerFirst(subDomainName),
-							Package: shorten.Lookup(genArgs.subDomain),
-						}},
-					}
-					intBody := &IntBody{
-						Name: intName,
-					}
-
-					for _, metRaw := range y.Methods.List {
-						metName := metRaw.Names[0].Name
-						if strings.HasPrefix(metName, "mustEmbedUnimplemented") {
-							continue
-						}
-						met := metRaw.Type.(*ast.FuncType)
-						methodBody := &MethodBody{
-							Name: metName,
-						}
-						for _, paramRaw := range met.Params.List {
-							if param, ok := paramRaw.Type.(*ast.SelectorExpr); ok {
-								paramType := fmt.Sprintf("%v.%v", param.X, param.Sel.Name)
-								paramName := shorten.Lookup(param.Sel.Name)
-								methodBody.Args = append(methodBody.Args, &Args{
-									Alias: paramName,
-									Type:  paramType,
-								})
-								continue
-							}
-							if param, ok := paramRaw.Type.(*ast.StarExpr); ok {
-								paramType := fmt.Sprint(param.X)
-								paramName := shorten.Lookup(paramType)
-								paramType = fmt.Sprintf("*%s.%s", pkgName, paramType)
-								methodBody.Args = append(methodBody.Args, &Args{
-									Alias: paramName,
-									Type:  paramType,
-								})
-								continue
-							}
-						}
-						for _, retRaw := range met.Results.List {
-							if ret, ok := retRaw.Type.(*ast.StarExpr); ok {
-								retType := fmt.Sprint(ret.X)
-								retType = fmt.Sprintf("*%s.%s", v.Name, retType)
-								methodBody.Returns = append(methodBody.Returns, &Args{
-									Type: retType,
-								})
-								continue
-							}
-							if ret, ok := retRaw.Type.(*ast.Ident); ok {
-								methodBody.Returns = append(methodBody.Returns, &Args{
-									Type: ret.Name,
-								})
-								continue
-							}
-						}
-						domainBody.Methods = append(domainBody.Methods, methodBody)
-						intBody.Methods = append(intBody.Methods, methodBody)
-					}
-					domainFile.Body = append(domainFile.Body, domainBody)
-					intFile.Body = append(intFile.Body, intBody)
-				default:
-				}
-				return true
-			})
-			domainFiles = append(domainFiles, domainFile)
-			for _, in := range intFile.Body {
-				intFiles[in.Name] = intFile
-			}
+	archive := generator.NewArchive(f, wd, format)
+	return archive, func() error {
+		if err := archive.Close(); err != nil {
+			f.Close()
+			return err
 		}
-	}
-
-	// entitiesPkgs, err := parser.ParseDir(fset, absPath, func(fi fs.FileInfo) bool {
-	// 	return strings.HasSuffix(fi.Name(), ".pb.go") && !strings.HasSuffix(fi.Name(), "_grpc.pb.go")
-	// }, parser.ParseComments)
-	// if err != nil {
-	// 	return err
-	// }
-
-	// for _, v := range entitiesPkgs {
-	// 	for fileName, fi := range v.Files {
-	// 		astutil.Apply(fi, nil, func(c *astutil.Cursor) bool {
-	// 			switch x := c.Node().(type) {
-	// 			case *ast.ImportSpec:
-	// 				if !strings.Contains(x.Path.Value, "entities") {
-	// 					return true
-	// 				}
-
-	// 				x.Name
-	// 				intGen.Imports = append(intGen.Imports, x.Path.Value)
-	// 			}
-
-	// 			return true
-	// 		})
-	// 		intGens = append(intGens, intGen)
-	// 	}
-	// }
-
-	if err := os.MkdirAll(outAbs, os.ModePerm); err != nil {
-		return err
-	}
+		return f.Close()
+	}, nil
+}
 
-	if err := os.MkdirAll(subOutAbs, os.ModePerm); err != nil {
+func printChange(w io.Writer, c generator.Change) error {
+	if _, err := fmt.Fprintf(w, "%s %s\n", c.Status(), c.RelName()); err != nil {
 		return err
 	}
-
-	for _, fi := range domainFiles {
-		if err := fi.WriteFile(genArgs.overWrite); err != nil {
-			ErrLog.Println(err)
-		}
-	}
-
-	for _, fi := range intFiles {
-		if err := fi.WriteFile(genArgs.overWrite); err != nil {
-			ErrLog.Println(err)
-		}
-	}
-
-	return nil
+	_, err := io.WriteString(w, c.Diff())
+	return err
 }
@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/dotdak/go-templater/generator"
+	"github.com/dotdak/go-templater/pkg/model"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+)
+
+var (
+	inspectCmd = &ffcli.Command{
+		Name:       "inspect",
+		ShortUsage: "gotem inspect [gen flags] [-format json|yaml]",
+		ShortHelp:  "Print the services read from the input",
+		LongHelp: "Prints the model gen renders from: the services, methods, message\n" +
+			"types and signatures read from -in, -proto, -descriptor-set or -model.\n" +
+			"The output is read back with gotem gen -model.",
+		FlagSet: inspectFlagSet,
+		Options: genOptions,
+		Exec:    inspect,
+	}
+
+	inspectFlagSet = func() *flag.FlagSet {
+		fs := genFlags(newFlagSet("inspect"))
+		fs.StringVar(&inspectArgs.format, "format", "json", "output format, json or yaml")
+		return fs
+	}()
+
+	inspectArgs struct {
+		format string
+	}
+)
+
+func inspect(ctx context.Context, args []string) error {
+	// keep stdout parseable
+	WarnLog.SetOutput(os.Stderr)
+
+	m := model.New()
+	err := eachJob(inspectFlagSet, func() error {
+		in, err := input()
+		if err != nil {
+			return err
+		}
+		jobModel, err := generator.ReadModel(ctx, generatorOptions(in))
+		if err != nil {
+			return err
+		}
+		m.Files = append(m.Files, jobModel.Files...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return m.Encode(os.Stdout, inspectArgs.format)
+}
@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/dotdak/go-templater/generator"
+
+	"github.com/peterbourgon/ff/v3/ffyaml"
+	"gopkg.in/yaml.v2"
+)
+
+var ErrNoJob = errors.New("no such job in the config file")
+
+// job is one generation run of the config file. Its keys are the gen flag
+// names, overriding the flags and the top level keys, plus a name and
+// optionally its own layers:
+//
+//	overwrite: false
+//	jobs:
+//	  - name: foo
+//	    proto: api/foo/v1/foo.proto
+//	    out: ./foo/handlers
+//	    subdomain-out: ./foo/services
+//	  - name: health
+//	    in: google.golang.org/grpc/health/grpc_health_v1@v1.58.3
+type job struct {
+	Name   string
+	Layers []*generator.Layer
+	flags  map[string]interface{}
+}
+
+// readJobs decodes the jobs key of the config file.
+func readJobs(raw interface{}) ([]*job, error) {
+	b, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var configs []map[string]interface{}
+	if err := yaml.Unmarshal(b, &configs); err != nil {
+		return nil, err
+	}
+
+	jobs := make([]*job, 0, len(configs))
+	seen := make(map[string]bool, len(configs))
+	for i, config := range configs {
+		name, _ := config["name"].(string)
+		if name == "" {
+			return nil, fmt.Errorf("job %d: name is required", i)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("job %s: declared twice", name)
+		}
+		seen[name] = true
+
+		j := &job{Name: name, flags: config}
+		if layers, ok := config["layers"]; ok {
+			b, err := yaml.Marshal(layers)
+			if err != nil {
+				return nil, err
+			}
+			if err := yaml.Unmarshal(b, &j.Layers); err != nil {
+				return nil, fmt.Errorf("job %s: %w", name, err)
+			}
+		}
+		delete(config, "name")
+		delete(config, "layers")
+		jobs = append(jobs, j)
+	}
+	return jobs, nil
+}
+
+// eachJob calls run once per job of the config file, or the one picked with
+// -job, with the job's keys set on fs. Without jobs run is called once with
+// the flags as they are.
+func eachJob(fs *flag.FlagSet, run func() error) error {
+	if len(genArgs.jobs) == 0 {
+		if genArgs.job != "" {
+			return fmt.Errorf("%s: %w", genArgs.job, ErrNoJob)
+		}
+		return run()
+	}
+
+	base := genArgs
+	defer func() { genArgs = base }()
+
+	var found bool
+	for _, j := range base.jobs {
+		if base.job != "" && base.job != j.Name {
+			continue
+		}
+		found = true
+
+		genArgs = base
+		if j.Layers != nil {
+			genArgs.layers = j.Layers
+		}
+		if err := j.apply(fs); err != nil {
+			return fmt.Errorf("job %s: %w", j.Name, err)
+		}
+		if err := run(); err != nil {
+			return fmt.Errorf("job %s: %w", j.Name, err)
+		}
+	}
+	if !found {
+		return fmt.Errorf("%s: %w", base.job, ErrNoJob)
+	}
+	return nil
+}
+
+func (j *job) apply(fs *flag.FlagSet) error {
+	b, err := yaml.Marshal(j.flags)
+	if err != nil {
+		return err
+	}
+	return ffyaml.Parser(bytes.NewReader(b), fs.Set)
+}
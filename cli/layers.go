@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/dotdak/go-templater/generator"
+
+	"github.com/peterbourgon/ff/v3/ffyaml"
+	"gopkg.in/yaml.v2"
+)
+
+var ErrLayers = generator.ErrLayers
+
+// configParser reads the config file with ffyaml, after taking out the
+// layers and jobs which aren't flags.
+func configParser(r io.Reader, set func(name, value string) error) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	var config map[string]interface{}
+	if err := yaml.Unmarshal(b, &config); err != nil {
+		return ffyaml.ParseError{Inner: err}
+	}
+	if _, ok := config["layers"]; ok {
+		var layers struct {
+			Layers []*generator.Layer `yaml:"layers"`
+		}
+		if err := yaml.Unmarshal(b, &layers); err != nil {
+			return ffyaml.ParseError{Inner: err}
+		}
+		genArgs.layers = layers.Layers
+		delete(config, "layers")
+	}
+	if raw, ok := config["jobs"]; ok {
+		jobs, err := readJobs(raw)
+		if err != nil {
+			return ffyaml.ParseError{Inner: err}
+		}
+		genArgs.jobs = jobs
+		delete(config, "jobs")
+	}
+
+	flags, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return ffyaml.Parser(bytes.NewReader(flags), set)
+}
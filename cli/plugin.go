@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dotdak/go-templater/generator"
+
+	"github.com/peterbourgon/ff/v3"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// pluginFlagSet takes the plugin options, reporting mistakes in the
+// response rather than exiting.
+var pluginFlagSet = genFlags(flag.NewFlagSet("protoc-gen-gotem", flag.ContinueOnError))
+
+// RunPlugin answers the CodeGeneratorRequest read from r with the files gen
+// would write for its protos. File names are relative to the working
+// directory, so run protoc with --gotem_out=. from the module root.
+func RunPlugin(r io.Reader, w io.Writer) error {
+	// stdout carries the response
+	WarnLog.SetOutput(os.Stderr)
+	pluginFlagSet.SetOutput(io.Discard)
+
+	in, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	req := &pluginpb.CodeGeneratorRequest{}
+	if err := proto.Unmarshal(in, req); err != nil {
+		return fmt.Errorf("read request: %w", err)
+	}
+
+	res := &pluginpb.CodeGeneratorResponse{
+		SupportedFeatures: proto.Uint64(uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)),
+	}
+	if res.File, err = pluginFiles(req); err != nil {
+		res.Error = proto.String(err.Error())
+	}
+
+	out, err := proto.Marshal(res)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+func pluginFiles(req *pluginpb.CodeGeneratorRequest) ([]*pluginpb.CodeGeneratorResponse_File, error) {
+	if err := pluginOptions(req.GetParameter()); err != nil {
+		return nil, err
+	}
+	// the input comes from protoc, so jobs only pick outputs when asked for
+	if genArgs.job == "" {
+		genArgs.jobs = nil
+	}
+
+	gen, err := protogen.Options{}.New(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*pluginpb.CodeGeneratorResponse_File
+	err = eachJob(pluginFlagSet, func() error {
+		opts := generatorOptions(generator.Plugin(gen))
+		opts.DryRun = true
+		res, err := generator.Generate(context.Background(), opts)
+		if err != nil {
+			return err
+		}
+
+		// unchanged files are sent too, buf may have cleaned them up
+		for _, c := range res.Changes {
+			name := c.RelName()
+			if filepath.IsAbs(name) || strings.HasPrefix(name, "../") {
+				return fmt.Errorf("%s: outside the working directory", c.Name)
+			}
+			files = append(files, &pluginpb.CodeGeneratorResponse_File{
+				Name:    proto.String(name),
+				Content: proto.String(string(c.After)),
+			})
+		}
+		return nil
+	})
+	return files, err
+}
+
+// pluginOptions sets the gen flags from the comma separated parameter, on
+// top of the config file. Options without a value are booleans.
+func pluginOptions(parameter string) error {
+	var args []string
+	for _, opt := range strings.Split(parameter, ",") {
+		if opt == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(opt, "=")
+		if !ok {
+			value = "true"
+		}
+		args = append(args, "-"+name+"="+value)
+	}
+	return ff.Parse(pluginFlagSet, args, genOptions...)
+}
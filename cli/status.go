@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/dotdak/go-templater/pkg/manifest"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+)
+
+var (
+	statusCmd = &ffcli.Command{
+		Name:       "status",
+		ShortUsage: "gotem status [gen flags]",
+		ShortHelp:  "Show the generated files edited by hand",
+		LongHelp: "Compares the files listed in the manifest, -lock, with the content\n" +
+			"gen wrote.",
+		FlagSet: statusFlagSet,
+		Options: genOptions,
+		Exec:    status,
+	}
+
+	statusFlagSet = genFlags(newFlagSet("status"))
+)
+
+// lockFile is a file of the manifest as found on the disk.
+type lockFile struct {
+	*manifest.File
+	// name is the path as given to -lock joined with the file's.
+	name  string
+	state string
+}
+
+// readLock reads the manifest called name and the state of its files: ok,
+// edited or missing.
+func readLock(name string) (*manifest.Manifest, []*lockFile, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	m, err := manifest.Decode(b)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	files := make([]*lockFile, 0, len(m.Files))
+	for _, f := range m.Files {
+		lf := &lockFile{File: f, name: filepath.Join(filepath.Dir(name), filepath.FromSlash(f.Path)), state: "ok"}
+		content, err := os.ReadFile(lf.name)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			lf.state = "missing"
+		case err != nil:
+			return nil, nil, err
+		case manifest.Sum(content) != f.Hash:
+			lf.state = "edited"
+		}
+		files = append(files, lf)
+	}
+	return m, files, nil
+}
+
+func status(ctx context.Context, args []string) error {
+	var names []string
+	seen := make(map[string]bool)
+	err := eachJob(statusFlagSet, func() error {
+		if genArgs.lock != "" && !seen[genArgs.lock] {
+			seen[genArgs.lock] = true
+			names = append(names, genArgs.lock)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		_, files, err := readLock(name)
+		if err != nil {
+			return err
+		}
+		if err := printStatus(os.Stdout, files); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printStatus(w io.Writer, files []*lockFile) error {
+	var edited, missing int
+	for _, f := range files {
+		switch f.state {
+		case "edited":
+			edited++
+		case "missing":
+			missing++
+		}
+		fmt.Fprintf(w, "%-8s %s\n", f.state, f.name)
+	}
+
+	_, err := fmt.Fprintf(w, "%d of %d generated files edited by hand, %d missing\n", edited, len(files), missing)
+	return err
+}
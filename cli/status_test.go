@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStatus(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	writeFiles(t, dir, map[string]string{
+		"go.mod":  "module example.com/cl\n\ngo 1.22\n",
+		"s.proto": service("s", "SService"),
+	})
+	gotem(t, genCmd, "-proto", "s.proto")
+
+	f, err := os.OpenFile(filepath.Join(dir, "services", "s_service_impl.go"), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("\n// edited\n")
+	f.Close()
+	if err := removeFile(dir, "services/s_service.go"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, files, err := readLock(".gotem.lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := printStatus(&b, files); err != nil {
+		t.Fatal(err)
+	}
+	want := `ok       handlers/v1/s_handler.go
+missing  services/s_service.go
+edited   services/s_service_impl.go
+1 of 3 generated files edited by hand, 1 missing
+`
+	if b.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
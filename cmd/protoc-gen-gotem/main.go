@@ -0,0 +1,17 @@
+// Command protoc-gen-gotem runs gotem as a protoc or buf plugin. Options
+// are the gen flags, as in --gotem_opt=out=handlers/v1,merge.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dotdak/go-templater/cli"
+)
+
+func main() {
+	if err := cli.RunPlugin(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
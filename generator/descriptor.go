@@ -0,0 +1,81 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/dotdak/go-templater/pkg/model"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+var ErrNotInSet = errors.New("not in the descriptor set")
+
+// DescriptorSet reads the services of a FileDescriptorSet, as written by
+// protoc --include_imports -o or buf build -o, from the files named as
+// imported, such as foo/v1/foo.proto. The set holds the imports along with
+// the protos it was built for, so without files only the files no other
+// file imports are read, and those skipped despite declaring services are
+// logged.
+func DescriptorSet(path string, files ...string) Source {
+	return pathSource{path: path, SourceFunc: func(_ context.Context, env Env) ([]*model.File, error) {
+		return readDescriptorSet(path, files, env.Log)
+	}}
+}
+
+func readDescriptorSet(in string, files []string, warn *log.Logger) ([]*model.File, error) {
+	b, err := os.ReadFile(in)
+	if err != nil {
+		return nil, err
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(b, set); err != nil {
+		return nil, fmt.Errorf("%s: %w", in, err)
+	}
+
+	req := &pluginpb.CodeGeneratorRequest{ProtoFile: set.File}
+	if len(files) > 0 {
+		inSet := make(map[string]bool, len(set.File))
+		for _, f := range set.File {
+			inSet[f.GetName()] = true
+		}
+		for _, name := range files {
+			if !inSet[name] {
+				return nil, fmt.Errorf("%s: %s: %w", in, name, ErrNotInSet)
+			}
+		}
+		req.FileToGenerate = files
+	} else {
+		imported := make(map[string]bool)
+		for _, f := range set.File {
+			for _, dep := range f.Dependency {
+				imported[dep] = true
+			}
+		}
+		for _, f := range set.File {
+			switch {
+			case len(f.Service) == 0:
+			case imported[f.GetName()]:
+				warn.Printf("%s: skipping %s, imported by another file, name the files to read them", in, f.GetName())
+			default:
+				req.FileToGenerate = append(req.FileToGenerate, f.GetName())
+			}
+		}
+	}
+
+	gen, err := protogen.Options{}.New(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", in, err)
+	}
+	read := readPlugin(gen)
+	if len(read) == 0 {
+		return nil, fmt.Errorf("%s: no services: %w", in, ErrNoInput)
+	}
+	return read, nil
+}
@@ -0,0 +1,133 @@
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dotdak/go-templater/pkg/model"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// testProtos are common.proto, declaring CommonService and Page, and
+// foo.proto importing it, as protoc --include_imports lists them.
+func testProtos(t *testing.T) []*descriptorpb.FileDescriptorProto {
+	t.Helper()
+	common := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("api/common/v1/common.proto"),
+		Package: proto.String("common.v1"),
+		Syntax:  proto.String("proto3"),
+		Options: &descriptorpb.FileOptions{GoPackage: proto.String("example.com/fx/api/common/v1;commonv1")},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("Page")},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("CommonService"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				{Name: proto.String("Ping"), InputType: proto.String(".common.v1.Page"), OutputType: proto.String(".common.v1.Page")},
+			},
+		}},
+	}
+	foo := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("api/foo/v1/foo.proto"),
+		Package:    proto.String("foo.v1"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"api/common/v1/common.proto"},
+		Options:    &descriptorpb.FileOptions{GoPackage: proto.String("example.com/fx/api/foo/v1;foov1")},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("Foo")},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("foo_service"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:            proto.String("list_foos"),
+				InputType:       proto.String(".common.v1.Page"),
+				OutputType:      proto.String(".foo.v1.Foo"),
+				ServerStreaming: proto.Bool(true),
+			}},
+		}},
+	}
+	files := []*descriptorpb.FileDescriptorProto{common, foo}
+	if _, err := protodesc.NewFiles(&descriptorpb.FileDescriptorSet{File: files}); err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// fooFile is what foo.proto of testProtos reads as.
+var fooFile = &model.File{
+	Path:      "api/foo/v1/foo.proto",
+	GoPackage: "example.com/fx/api/foo/v1",
+	GoName:    "foov1",
+	Imports: []*model.Import{
+		{Name: "context", Path: "context"},
+		{Name: "commonv1", Path: "example.com/fx/api/common/v1"},
+		{Name: "foov1", Path: "example.com/fx/api/foo/v1"},
+	},
+	Services: []*model.Service{{
+		Name:   "Foo",
+		Server: "FooServiceServer",
+		Methods: []*model.Method{{
+			Name:       "ListFoos",
+			Stream:     ServerStream,
+			Request:    "commonv1.Page",
+			Response:   "foov1.Foo",
+			RequestArg: "page",
+			Args: []*model.Arg{
+				{Name: "page", Type: "*commonv1.Page"},
+				{Name: "stream", Type: "foov1.FooService_ListFoosServer"},
+			},
+			Returns: []*model.Arg{{Type: "error"}},
+		}},
+	}},
+}
+
+func TestReadDescriptorSet(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "set.binpb")
+	b, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: testProtos(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("not imported", func(t *testing.T) {
+		var warnings bytes.Buffer
+		files, err := readDescriptorSet(name, nil, log.New(&warnings, "", 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != 1 || !reflect.DeepEqual(files[0], fooFile) {
+			t.Errorf("read\n%s\nwant\n%s", dump(t, files), dump(t, []*model.File{fooFile}))
+		}
+		want := name + ": skipping api/common/v1/common.proto, imported by another file, name the files to read them\n"
+		if warnings.String() != want {
+			t.Errorf("warnings = %q, want %q", warnings.String(), want)
+		}
+	})
+
+	t.Run("named", func(t *testing.T) {
+		files, err := readDescriptorSet(name, []string{"api/common/v1/common.proto"}, log.New(os.Stderr, "", 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != 1 || files[0].Path != "api/common/v1/common.proto" || files[0].Services[0].Name != "Common" {
+			t.Errorf("read\n%s\nwant CommonService of common.proto", dump(t, files))
+		}
+	})
+
+	t.Run("not in set", func(t *testing.T) {
+		_, err := readDescriptorSet(name, []string{"api/bar/v1/bar.proto"}, log.New(os.Stderr, "", 0))
+		if !errors.Is(err, ErrNotInSet) {
+			t.Errorf("readDescriptorSet error = %v, want %v", err, ErrNotInSet)
+		}
+	})
+}
@@ -0,0 +1,168 @@
+package generator
+
+import (
+	_ "embed"
+	"strings"
+
+	"github.com/dotdak/go-templater/pkg/model"
+	"github.com/dotdak/go-templater/pkg/shorten"
+
+	"golang.org/x/tools/imports"
+)
+
+//go:embed sample/domain
+var sample string
+
+type DomainGenerator struct {
+	FileName       string
+	Imports        []*Import
+	ImportPackage  string
+	Package        string
+	Domain         string
+	ServicePackage string
+	Body           []*DomainBody
+	// Template names the template the file is rendered with.
+	Template string
+
+	origin origin
+}
+
+type Injector struct {
+	Alias   string
+	Name    string
+	Package string
+}
+
+// Type is the injected interface, qualified unless it lives in the same
+// package.
+func (i *Injector) Type() string {
+	if i.Package == "" {
+		return i.Name
+	}
+	return i.Package + "." + i.Name
+}
+
+type Import struct {
+	Name string
+	Path string
+}
+
+type DomainBody struct {
+	ServiceName string
+	Server      string
+	Comment     string
+	Injectors   []*Injector
+	Methods     []*MethodBody
+	Args        []*Args
+	Returns     []*Args
+}
+
+// StreamKind tells which sides of an rpc stream messages.
+type StreamKind = model.StreamKind
+
+const (
+	Unary        = model.Unary
+	ServerStream = model.ServerStream
+	ClientStream = model.ClientStream
+	BidiStream   = model.BidiStream
+)
+
+func streamKind(client, server bool) StreamKind {
+	switch {
+	case client && server:
+		return BidiStream
+	case client:
+		return ClientStream
+	case server:
+		return ServerStream
+	default:
+		return Unary
+	}
+}
+
+type MethodBody struct {
+	Comment string
+	Name    string
+	Args    []*Args
+	Returns []*Args
+	Stream  StreamKind
+	// RequestArg is the name of the request argument, empty when the
+	// requests arrive through the stream.
+	RequestArg string
+	// RequestType and ResponseType are the qualified message types without
+	// the pointer.
+	RequestType  string
+	ResponseType string
+	// ServiceArgs and ServiceReturns make up the service layer signature,
+	// which swaps gRPC streams for channels.
+	ServiceArgs    []*Args
+	ServiceReturns []*Args
+}
+
+// setServiceSignature derives the service layer signature from the handler
+// one. Unary methods keep theirs, streams become channels of messages.
+func (m *MethodBody) setServiceSignature(ctxType string) {
+	ctx := &Args{Alias: shorten.Lookup("context"), Type: ctxType}
+	in := &Args{Alias: "in", Type: "<-chan *" + m.RequestType}
+	out := &Args{Alias: "out", Type: "chan<- *" + m.ResponseType}
+	errType := &Args{Type: "error"}
+
+	switch m.Stream {
+	case ServerStream:
+		m.ServiceArgs = []*Args{ctx, {Alias: m.RequestArg, Type: "*" + m.RequestType}, out}
+		m.ServiceReturns = []*Args{errType}
+	case ClientStream:
+		m.ServiceArgs = []*Args{ctx, in}
+		m.ServiceReturns = []*Args{{Type: "*" + m.ResponseType}, errType}
+	case BidiStream:
+		m.ServiceArgs = []*Args{ctx, in, out}
+		m.ServiceReturns = []*Args{errType}
+	default:
+		m.ServiceArgs = m.Args
+		m.ServiceReturns = m.Returns
+	}
+}
+
+// ServiceCall calls the service layer method with the ServiceArgs by name,
+// as in GetFoo(ctx, req).
+func (m *MethodBody) ServiceCall() string {
+	names := make([]string, 0, len(m.ServiceArgs))
+	for _, arg := range m.ServiceArgs {
+		if strings.HasPrefix(arg.Type, "...") {
+			names = append(names, arg.Alias+"...")
+			continue
+		}
+		names = append(names, arg.Alias)
+	}
+	return m.Name + "(" + strings.Join(names, ", ") + ")"
+}
+
+type Args struct {
+	Alias string
+	Type  string
+}
+
+func (g *DomainGenerator) name() string {
+	return g.FileName
+}
+
+func (g *DomainGenerator) template() (string, string) {
+	return g.Template, sample
+}
+
+func (g *DomainGenerator) from() origin {
+	return g.origin
+}
+
+func (g *DomainGenerator) render(r *run) ([]byte, error) {
+	return r.render(g.FileName, g.Template, sample, g.Imports, g, g.origin.scaffolded())
+}
+
+// formatSource gofmts src and drops the template imports it doesn't use.
+func formatSource(fileName string, src []byte) ([]byte, error) {
+	return imports.Process(fileName, src, &imports.Options{
+		Comments:  true,
+		TabIndent: true,
+		TabWidth:  8,
+	})
+}
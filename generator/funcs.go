@@ -0,0 +1,157 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path"
+	"strings"
+	"text/template"
+
+	"github.com/dotdak/go-templater/pkg/naming"
+	"github.com/dotdak/go-templater/pkg/shorten"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// templateFuncs returns the functions available to every template:
+//
+//	lookup "request"          shorten.Lookup, "req"
+//	lowerFirst "FooService"   shorten.LowerFirst, "fooService"
+//	trimService "FooServer"   shorten.TrimServiceName, "Foo"
+//	snake "GetUserID"         "get_user_id"
+//	kebab "GetUserID"         "get-user-id"
+//	camel "get_user_id"       "getUserID"
+//	pascal "get_user_id"      "GetUserID"
+//	plural "UserProfile"      "UserProfiles"
+//	singular "UserProfiles"   "UserProfile"
+//	wrap 76 .Text             wraps text at the given width
+//	comment .Text             prefixes every line with "// "
+//	trimStar "*foov1.Foo"     "foov1.Foo"
+//	dict "Arg" .RequestArg    a map of the key value pairs, to hand several
+//	                          values to a nested template
+//	import "go.uber.org/zap"  adds the import to the file and returns its
+//	                          name, an optional second argument picks it
+//
+// Imports registered through import are merged into the rendered file, so
+// templates don't need to list them in the import block.
+func templateFuncs(imports *importSet) template.FuncMap {
+	return template.FuncMap{
+		"lookup":      shorten.Lookup,
+		"lowerFirst":  shorten.LowerFirst,
+		"trimService": shorten.TrimServiceName,
+		"snake":       naming.Snake,
+		"kebab":       naming.Kebab,
+		"camel":       naming.Camel,
+		"pascal":      naming.Pascal,
+		"plural":      naming.Plural,
+		"singular":    naming.Singular,
+		"wrap":        wrap,
+		"comment":     comment,
+		"trimStar": func(typ string) string {
+			return strings.TrimPrefix(typ, "*")
+		},
+		"dict": dict,
+		"import": func(importPath string, name ...string) string {
+			if len(name) > 0 {
+				return imports.add(importPath, name[0])
+			}
+			return imports.add(importPath, assumedName(importPath))
+		},
+	}
+}
+
+// renderImports seeds an import set with the imports a generator already
+// lists, so names handed out by the import template function don't clash.
+func renderImports(existing []*Import) *importSet {
+	s := newImportSet()
+	for _, imp := range existing {
+		name := imp.Name
+		if name == "" {
+			name = assumedName(imp.Path)
+		}
+		s.add(imp.Path, name)
+	}
+	return s
+}
+
+// assumedName guesses the package name of importPath the way goimports
+// does: the last element without a major version or go- prefix.
+func assumedName(importPath string) string {
+	base := path.Base(importPath)
+	if strings.HasPrefix(base, "v") && strings.Trim(base[1:], "0123456789") == "" && path.Dir(importPath) != "." {
+		base = path.Base(path.Dir(importPath))
+	}
+	base = strings.TrimPrefix(base, "go-")
+	if i := strings.IndexAny(base, ".-"); i >= 0 {
+		base = base[:i]
+	}
+	return base
+}
+
+// addImports inserts the imports registered while executing a template
+// beyond the first skip into src.
+func addImports(src []byte, imports *importSet, skip int) ([]byte, error) {
+	if len(imports.imports) <= skip {
+		return src, nil
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	for _, imp := range imports.imports[skip:] {
+		astutil.AddNamedImport(fset, f, imp.Name, imp.Path)
+	}
+
+	var b bytes.Buffer
+	if err := printer.Fprint(&b, fset, f); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func dict(pairs ...any) (map[string]any, error) {
+	if len(pairs)%2 != 0 {
+		return nil, ErrOddParam
+	}
+	m := make(map[string]any, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict key %v is not a string", pairs[i])
+		}
+		m[key] = pairs[i+1]
+	}
+	return m, nil
+}
+
+func wrap(width int, text string) string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		var line string
+		for _, word := range strings.Fields(paragraph) {
+			if line != "" && len(line)+1+len(word) > width {
+				lines = append(lines, line)
+				line = ""
+			}
+			if line != "" {
+				line += " "
+			}
+			line += word
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func comment(text string) string {
+	text = strings.TrimRight(text, "\n")
+	if text == "" {
+		return ""
+	}
+	return "// " + strings.ReplaceAll(text, "\n", "\n// ")
+}
@@ -0,0 +1,343 @@
+// Package generator renders gRPC handlers, and the layers they are built
+// on, from the services read from an input. The gotem command is a thin
+// wrapper around Generate:
+//
+//	res, err := generator.Generate(ctx, generator.Options{
+//		Input:     generator.Proto("api/foo/v1/foo.proto"),
+//		Overwrite: true,
+//	})
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"sort"
+
+	"github.com/dotdak/go-templater/pkg/model"
+	"github.com/dotdak/go-templater/pkg/modpath"
+)
+
+var (
+	ErrNoInput  = errors.New("no input file")
+	ErrStale    = errors.New("stale must be report, deprecate or move")
+	ErrOddParam = errors.New("missing params or values")
+	ErrClash    = errors.New("generated from several inputs")
+)
+
+// Options configure a run. Relative paths are resolved from the working
+// directory, whose module gives the import paths of the outputs.
+type Options struct {
+	// Input is where the services are read from.
+	Input Source
+	// Layers is the chain generated for every service, a Handler in
+	// ./handlers/v1 built on a Service in ./services when empty.
+	Layers []*Layer
+	// NoImpl skips the implementations of the layers after the first.
+	NoImpl bool
+	// Templates is a directory overriding the embedded templates.
+	Templates string
+	// Overwrite replaces existing generated files and adds what the
+	// scaffolds, the files headed as safe to edit, lack. Merge adds what
+	// every file lacks instead. Without either existing files are left
+	// alone.
+	Overwrite bool
+	Merge     bool
+	// Force replaces or merges into files without a gotem header, which
+	// are refused otherwise, and has Overwrite replace scaffolds too.
+	Force bool
+	// Stale is what Merge does with methods whose RPC was removed,
+	// StaleReport when empty.
+	Stale string
+	// DryRun plans the changes without writing them.
+	DryRun bool
+	// Manifest is the lock file recording the files written, read through
+	// the sink to skip the ones whose input didn't change. None is kept
+	// when empty.
+	Manifest string
+	// Sink is where the files are read from and written to, Disk when
+	// nil.
+	Sink Sink
+	// Parallel is how many files are rendered, or written, at once,
+	// GOMAXPROCS when zero. The sink is called from as many goroutines.
+	Parallel int
+	// Log receives the warnings, which are dropped when nil.
+	Log *log.Logger
+}
+
+// Source reads the services to generate from. See Proto, DescriptorSet,
+// GoPackage, ModelFile, Model and Plugin.
+type Source interface {
+	Read(ctx context.Context, env Env) ([]*model.File, error)
+}
+
+// SourceFunc adapts a function to a Source.
+type SourceFunc func(ctx context.Context, env Env) ([]*model.File, error)
+
+func (f SourceFunc) Read(ctx context.Context, env Env) ([]*model.File, error) {
+	return f(ctx, env)
+}
+
+// pathSource is a source reading path, which the manifest records for
+// gotem clean to tell the files of other sources apart.
+type pathSource struct {
+	SourceFunc
+	path string
+}
+
+// Env is what a Source gets from the run.
+type Env struct {
+	// Resolver maps directories to import paths as seen from the module
+	// of the working directory.
+	Resolver *modpath.Resolver
+	Log      *log.Logger
+}
+
+// Result is what a run did.
+type Result struct {
+	// Changes holds every file of the run in generation order, unchanged
+	// ones included.
+	Changes []Change
+	// Inputs holds the paths of the input files read and Dirs the
+	// directories of the layers, which gotem clean limits itself to.
+	Inputs []string
+	Dirs   []string
+	// Source is where the input was read from, as recorded in the
+	// manifest. Empty without one.
+	Source string
+}
+
+// Generate reads the services of opts.Input, lays them out in every layer
+// and writes the files that changed to the sink, in parallel.
+//
+// Inputs generating the same file fail the run with ErrClash up front.
+// Every file is rendered even when some fail, their errors joined in
+// generation order, and nothing is written unless all of them rendered.
+// Writing stops at the first failure instead, and the files written by
+// then get their previous content back, or are removed when new. When ctx is done the files not
+// started yet are skipped and its error returned, a partial write rolled
+// back the same way.
+func Generate(ctx context.Context, opts Options) (*Result, error) {
+	r, err := newRun(opts)
+	if err != nil {
+		return nil, err
+	}
+	layers, err := resolveLayers(r.resolver, r.opts.Layers)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.readLock(layers); err != nil {
+		return nil, err
+	}
+	files, err := r.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	domainFiles, intFiles := newGenerators(layers, files)
+	genFiles := chainLayers(layers, domainFiles, intFiles, !r.opts.NoImpl)
+	if err := clashes(genFiles); err != nil {
+		return nil, err
+	}
+	results := make([]planned, len(genFiles))
+	cerr := forEach(ctx, r.opts.Parallel, len(genFiles), func(i int) {
+		results[i] = r.planLogged(genFiles[i])
+	})
+
+	res := &Result{}
+	for _, f := range files {
+		res.Inputs = append(res.Inputs, f.Path)
+	}
+	for _, l := range layers {
+		res.Dirs = append(res.Dirs, l.dir)
+	}
+	if r.lock != nil {
+		res.Source = r.lock.source
+	}
+	var errs []error
+	for i, p := range results {
+		r.log.Writer().Write(p.log)
+		if p.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", genFiles[i].name(), p.err))
+			continue
+		}
+		res.Changes = append(res.Changes, p.changes...)
+	}
+	if cerr != nil {
+		errs = append(errs, cerr)
+	}
+	if len(errs) > 0 || r.opts.DryRun {
+		return res, errors.Join(errs...)
+	}
+
+	lock, ok, err := r.lockChange(res.Changes)
+	if err != nil {
+		return res, err
+	}
+	if !ok {
+		return res, r.commit(ctx, res.Changes, nil)
+	}
+	return res, r.commit(ctx, res.Changes, &lock)
+}
+
+// clashes reports the files generated from more than one input, as inputs
+// in different packages may share a file name.
+func clashes(files []file) error {
+	from := make(map[string]string, len(files))
+	var errs []error
+	for _, f := range files {
+		path := f.from().path
+		prev, ok := from[f.name()]
+		if !ok {
+			from[f.name()] = path
+			continue
+		}
+		if prev != path {
+			errs = append(errs, fmt.Errorf("%s: %w, %s and %s", f.name(), ErrClash, prev, path))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// commit writes the changes, then the manifest when not nil. When a write
+// fails, or ctx is done, the writes not started yet are skipped and the
+// ones done rolled back.
+func (r *run) commit(ctx context.Context, changes []Change, manifest *Change) error {
+	var pending []Change
+	for _, c := range changes {
+		if c.Status() != "unchanged" {
+			pending = append(pending, c)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make([]error, len(pending))
+	done := make([]bool, len(pending))
+	cerr := forEach(ctx, r.opts.Parallel, len(pending), func(i int) {
+		c := pending[i]
+		if err := r.opts.Sink.WriteFile(c.Name, c.After); err != nil {
+			errs[i] = fmt.Errorf("write %s: %w", c.Name, err)
+			cancel()
+			return
+		}
+		done[i] = true
+	})
+
+	// a failed write cancels ctx too, only report the caller's cancellation
+	err := errors.Join(errs...)
+	if err == nil {
+		err = cerr
+	}
+	if err == nil && manifest != nil && manifest.Status() != "unchanged" {
+		if werr := r.opts.Sink.WriteFile(manifest.Name, manifest.After); werr != nil {
+			err = fmt.Errorf("write %s: %w", manifest.Name, werr)
+		}
+	}
+	if err == nil {
+		return nil
+	}
+
+	var written []Change
+	for i, c := range pending {
+		if done[i] {
+			written = append(written, c)
+		}
+	}
+	return errors.Join(err, r.rollback(written))
+}
+
+func (r *run) rollback(written []Change) error {
+	var errs []error
+	for i := len(written) - 1; i >= 0; i-- {
+		c := written[i]
+		var err error
+		if c.Before == nil {
+			err = r.opts.Sink.Remove(c.Name)
+		} else {
+			err = r.opts.Sink.WriteFile(c.Name, c.Before)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("roll back %s: %w", c.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ReadModel reads the services of opts.Input, as Generate would before
+// rendering them.
+func ReadModel(ctx context.Context, opts Options) (*model.Model, error) {
+	r, err := newRun(opts)
+	if err != nil {
+		return nil, err
+	}
+	files, err := r.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m := model.New()
+	m.Files = files
+	return m, nil
+}
+
+// run is the state of one Generate call.
+type run struct {
+	opts     Options
+	log      *log.Logger
+	resolver *modpath.Resolver
+	// lock is nil without Options.Manifest.
+	lock      *lock
+	templates *templates
+}
+
+func newRun(opts Options) (*run, error) {
+	switch opts.Stale {
+	case "":
+		opts.Stale = StaleReport
+	case StaleReport, StaleDeprecate, StaleMove:
+	default:
+		return nil, ErrStale
+	}
+	if opts.Sink == nil {
+		opts.Sink = Disk{}
+	}
+	if opts.Parallel <= 0 {
+		opts.Parallel = runtime.GOMAXPROCS(0)
+	}
+
+	r := &run{opts: opts, log: opts.Log, templates: newTemplates(opts.Templates)}
+	if r.log == nil {
+		r.log = log.New(io.Discard, "", 0)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	if r.resolver, err = modpath.NewResolver(wd); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// read reads the files of the input sorted by path, so the files are
+// generated and logged in the same order whatever the source. Services and
+// methods keep their declaration order.
+func (r *run) read(ctx context.Context) ([]*model.File, error) {
+	if r.opts.Input == nil {
+		return nil, ErrNoInput
+	}
+	files, err := r.opts.Input.Read(ctx, Env{Resolver: r.resolver, Log: r.log})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files, nil
+}
@@ -0,0 +1,270 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const fooService = `message GetFooRequest {}
+message Foo {}
+
+service FooService {
+  rpc GetFoo(GetFooRequest) returns (Foo);
+}
+`
+
+func TestGenerateLayers(t *testing.T) {
+	layers := func() []*Layer {
+		return []*Layer{
+			{Name: "Handler", Out: "./handlers"},
+			{Name: "UseCase", Out: "./usecases", Inject: []string{"Repo"}},
+			{Name: "Repo", Out: "./repos", Template: "none"},
+		}
+	}
+	given := layers()
+	first := render(t, fooProto(fooService), Options{Layers: given})
+	if !reflect.DeepEqual(given, layers()) {
+		t.Fatalf("Generate changed the layers it was given")
+	}
+
+	// runs sharing the layers neither race nor see each other's
+	var wg sync.WaitGroup
+	sinks := make([]*Memory, 4)
+	errs := make([]error, len(sinks))
+	for i := range sinks {
+		sinks[i] = NewMemory()
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = Generate(context.Background(), Options{
+				Input:  Proto("api/foo/v1/foo.proto"),
+				Layers: given,
+				Sink:   sinks[i],
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	for i, sink := range sinks {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if !reflect.DeepEqual(sink.Names(), first.Names()) {
+			t.Fatalf("run %d wrote %v, want %v", i, sink.Names(), first.Names())
+		}
+		for _, name := range first.Names() {
+			want, _ := first.ReadFile(name)
+			if got, _ := sink.ReadFile(name); !bytes.Equal(got, want) {
+				t.Errorf("run %d: %s =\n%s\nwant\n%s", i, name, got, want)
+			}
+		}
+	}
+	if !reflect.DeepEqual(given, layers()) {
+		t.Errorf("Generate changed the layers it was given")
+	}
+}
+
+var errDiskFull = errors.New("disk full")
+
+// faultySink is a Sink failing the writes of the file called fail, and
+// calling cancel, when set, after the others. It records the base names
+// written, in order.
+type faultySink struct {
+	Sink
+	fail   string
+	cancel func()
+
+	mu     sync.Mutex
+	writes []string
+}
+
+func (s *faultySink) WriteFile(name string, data []byte) error {
+	s.mu.Lock()
+	s.writes = append(s.writes, filepath.Base(name))
+	s.mu.Unlock()
+	if filepath.Base(name) == s.fail {
+		return errDiskFull
+	}
+	if err := s.Sink.WriteFile(name, data); err != nil {
+		return err
+	}
+	if s.cancel != nil {
+		s.cancel()
+	}
+	return nil
+}
+
+// threeLayers chains Handler, UseCase and Repo, the files of fooService
+// being generated in that order.
+func threeLayers() []*Layer {
+	return []*Layer{
+		{Name: "Handler", Out: "./handlers"},
+		{Name: "UseCase", Out: "./usecases"},
+		{Name: "Repo", Out: "./repos"},
+	}
+}
+
+// contents returns the files of sink by name.
+func contents(t *testing.T, sink *Memory) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	for _, name := range sink.Names() {
+		b, err := sink.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[name] = string(b)
+	}
+	return files
+}
+
+func TestGenerateRenderErrors(t *testing.T) {
+	files := fooProto(fooService)
+	files["tmpl/impl"] = "{{ .Nope }}"
+	fixture(t, files)
+
+	sink := &faultySink{Sink: NewMemory()}
+	_, err := Generate(context.Background(), Options{
+		Input:     Proto("api/foo/v1/foo.proto"),
+		Layers:    threeLayers(),
+		Templates: "tmpl",
+		Sink:      sink,
+		Parallel:  4,
+	})
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		t.Fatalf("Generate error = %v, want the render errors joined", err)
+	}
+	errs := joined.Unwrap()
+	if len(errs) != 2 ||
+		!strings.Contains(errs[0].Error(), "foo_usecase_impl.go") ||
+		!strings.Contains(errs[1].Error(), "foo_repo_impl.go") {
+		t.Errorf("Generate errors = %q, want those of foo_usecase_impl.go and foo_repo_impl.go in order", errs)
+	}
+	if len(sink.writes) > 0 {
+		t.Errorf("wrote %v, want nothing as some renders failed", sink.writes)
+	}
+}
+
+func TestGenerateWriteError(t *testing.T) {
+	tests := []struct {
+		fail string
+		// writes are the writes up to the failed one, then those rolling
+		// back the files replaced. The files created are removed.
+		writes []string
+	}{
+		{
+			fail:   "foo_usecase.go",
+			writes: []string{"foo_handler.go", "foo_usecase.go", "foo_handler.go"},
+		},
+		{
+			fail: "foo_repo_impl.go",
+			writes: []string{
+				"foo_handler.go", "foo_usecase.go", "foo_usecase_impl.go", "foo_repo.go", "foo_repo_impl.go",
+				"foo_usecase_impl.go", "foo_usecase.go", "foo_handler.go",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fail, func(t *testing.T) {
+			// the second run changes the files of the first and creates
+			// those of Repo
+			mem := render(t, fooProto(fooService), Options{Layers: threeLayers()[:2]})
+			before := contents(t, mem)
+			writeFiles(t, ".", fooProto(strings.Replace(fooService,
+				"rpc GetFoo(GetFooRequest) returns (Foo);",
+				"rpc GetFoo(GetFooRequest) returns (Foo);\n  rpc ListFoos(GetFooRequest) returns (Foo);", 1)))
+
+			sink := &faultySink{Sink: mem, fail: tt.fail}
+			_, err := Generate(context.Background(), Options{
+				Input:     Proto("api/foo/v1/foo.proto"),
+				Layers:    threeLayers(),
+				Overwrite: true,
+				Sink:      sink,
+				Parallel:  1,
+			})
+			if !errors.Is(err, errDiskFull) {
+				t.Fatalf("Generate error = %v, want %v", err, errDiskFull)
+			}
+			if !reflect.DeepEqual(sink.writes, tt.writes) {
+				t.Errorf("writes = %v, want %v", sink.writes, tt.writes)
+			}
+			if after := contents(t, mem); !reflect.DeepEqual(after, before) {
+				t.Errorf("rolled back to %v, want %v", after, before)
+			}
+		})
+	}
+}
+
+func TestGenerateRollbackDirs(t *testing.T) {
+	dir := fixture(t, fooProto(fooService))
+	sink := &faultySink{Sink: Disk{}, fail: "foo_repo_impl.go"}
+	_, err := Generate(context.Background(), Options{
+		Input:    Proto("api/foo/v1/foo.proto"),
+		Layers:   threeLayers(),
+		Sink:     sink,
+		Parallel: 1,
+	})
+	if !errors.Is(err, errDiskFull) {
+		t.Fatalf("Generate error = %v, want %v", err, errDiskFull)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if want := []string{"api", "go.mod"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("left %v, want %v", names, want)
+	}
+}
+
+func TestGenerateCancel(t *testing.T) {
+	fixture(t, fooProto(fooService))
+
+	t.Run("before", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		sink := &faultySink{Sink: NewMemory()}
+		res, err := Generate(ctx, Options{Input: Proto("api/foo/v1/foo.proto"), Sink: sink})
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Generate error = %v, want %v", err, context.Canceled)
+		}
+		if len(res.Changes) > 0 || len(sink.writes) > 0 {
+			t.Errorf("planned %d files and wrote %v, want none", len(res.Changes), sink.writes)
+		}
+	})
+
+	t.Run("writing", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		mem := NewMemory()
+		sink := &faultySink{Sink: mem, cancel: cancel}
+		_, err := Generate(ctx, Options{
+			Input:    Proto("api/foo/v1/foo.proto"),
+			Layers:   threeLayers(),
+			Sink:     sink,
+			Parallel: 1,
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Generate error = %v, want %v", err, context.Canceled)
+		}
+		if want := []string{"foo_handler.go"}; !reflect.DeepEqual(sink.writes, want) {
+			t.Errorf("writes = %v, want %v", sink.writes, want)
+		}
+		if names := mem.Names(); len(names) > 0 {
+			t.Errorf("left %v, want the written files rolled back", names)
+		}
+	})
+}
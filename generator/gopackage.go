@@ -0,0 +1,340 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dotdak/go-templater/pkg/model"
+	"github.com/dotdak/go-templater/pkg/modpath"
+	"github.com/dotdak/go-templater/pkg/shorten"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// GoPackage reads the FooServiceServer interfaces of the _grpc.pb.go files
+// in a package directory or a module@version, either may end with /... to
+// take every package below.
+func GoPackage(in string) Source {
+	return pathSource{path: in, SourceFunc: func(ctx context.Context, env Env) ([]*model.File, error) {
+		return readGoPackage(ctx, in, env.Resolver)
+	}}
+}
+
+// load type checks the packages matching patterns from dir, the working
+// directory when empty. Dependencies come from export data, so only the
+// matched packages carry syntax.
+func load(ctx context.Context, dir string, patterns ...string) ([]*packages.Package, []error) {
+	cfg := &packages.Config{
+		Context: ctx,
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles |
+			packages.NeedImports | packages.NeedTypes |
+			packages.NeedSyntax | packages.NeedTypesInfo,
+		Dir: dir,
+		Env: os.Environ(),
+	}
+
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	var errs []error
+	for _, p := range pkgs {
+		for _, e := range p.Errors {
+			errs = append(errs, e)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return pkgs, nil
+}
+
+// inputDirs expands in into package directories. Module queries such as
+// example.com/api/foo/v1@v1.4.2 resolve into the module cache, and a trailing
+// /... matches every directory below that holds gRPC stubs.
+func inputDirs(in string, resolver *modpath.Resolver) ([]string, error) {
+	pkgPath, version, _ := strings.Cut(in, "@")
+	recursive := strings.HasSuffix(pkgPath, "/...")
+	pkgPath = strings.TrimSuffix(pkgPath, "/...")
+
+	var root string
+	if version != "" {
+		dir, err := resolver.CachedDir(pkgPath, version)
+		if err != nil {
+			return nil, err
+		}
+		root = dir
+	} else {
+		dir, err := filepath.Abs(pkgPath)
+		if err != nil {
+			return nil, err
+		}
+		root = dir
+	}
+
+	if !recursive {
+		return []string{root}, nil
+	}
+
+	var dirs []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path != root && (d.Name() == "vendor" || d.Name() == "testdata" || strings.HasPrefix(d.Name(), ".")) {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && strings.HasSuffix(d.Name(), "_grpc.pb.go") {
+			if dir := filepath.Dir(path); len(dirs) == 0 || dirs[len(dirs)-1] != dir {
+				dirs = append(dirs, dir)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("%s: %w", in, ErrNoInput)
+	}
+
+	return dirs, nil
+}
+
+func readGoPackage(ctx context.Context, in string, resolver *modpath.Resolver) ([]*model.File, error) {
+	dirs, err := inputDirs(in, resolver)
+	if err != nil {
+		return nil, fmt.Errorf("resolve %s: %w", in, err)
+	}
+
+	pkgPaths := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		pkgPath, err := resolver.ImportPath(dir)
+		if err != nil {
+			return nil, fmt.Errorf("resolve %s: %w", dir, err)
+		}
+		pkgPaths = append(pkgPaths, pkgPath)
+	}
+
+	pkgs, errs := load(ctx, "", pkgPaths...)
+	if len(errs) > 0 {
+		// packages outside the main module, e.g. module@version inputs,
+		// still load from their own modules
+		pkgs, errs = loadByModule(ctx, dirs)
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("load %s: %w", in, errors.Join(errs...))
+	}
+
+	var files []*model.File
+	for _, pkg := range pkgs {
+		files = append(files, readGoFiles(pkg)...)
+	}
+	return files, nil
+}
+
+// loadByModule loads dirs from the module each of them belongs to, once
+// per module.
+func loadByModule(ctx context.Context, dirs []string) ([]*packages.Package, []error) {
+	var roots []string
+	patterns := make(map[string][]string)
+	for _, dir := range dirs {
+		root := moduleRoot(dir)
+		rel, err := filepath.Rel(root, dir)
+		if err != nil {
+			return nil, []error{err}
+		}
+		if patterns[root] == nil {
+			roots = append(roots, root)
+		}
+		patterns[root] = append(patterns[root], "./"+filepath.ToSlash(rel))
+	}
+
+	var pkgs []*packages.Package
+	for _, root := range roots {
+		rootPkgs, errs := load(ctx, root, patterns[root]...)
+		if len(errs) > 0 {
+			return nil, errs
+		}
+		pkgs = append(pkgs, rootPkgs...)
+	}
+	return pkgs, nil
+}
+
+// moduleRoot is the closest directory holding dir with a go.mod, dir
+// itself when there is none.
+func moduleRoot(dir string) string {
+	for d := dir; ; {
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			return d
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return dir
+		}
+		d = parent
+	}
+}
+
+// readGoFiles reads the services of the _grpc.pb.go files of pkg.
+func readGoFiles(pkg *packages.Package) []*model.File {
+	var files []*model.File
+	for i, fi := range pkg.Syntax {
+		fileName := filepath.Base(pkg.CompiledGoFiles[i])
+		if !strings.HasSuffix(fileName, "_grpc.pb.go") {
+			continue
+		}
+
+		imports := newImportSet()
+		f := &model.File{
+			Path:      pkg.PkgPath + "/" + fileName,
+			GoPackage: pkg.PkgPath,
+			GoName:    imports.add(pkg.PkgPath, pkg.Name),
+		}
+		for _, decl := range fi.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				if service, ok := readServer(pkg, spec.(*ast.TypeSpec), imports); ok {
+					f.Services = append(f.Services, service)
+				}
+			}
+		}
+
+		if len(f.Services) == 0 {
+			continue
+		}
+		f.Imports = imports.modelImports()
+		files = append(files, f)
+	}
+	return files
+}
+
+// readServer reads the service of a FooServiceServer interface, reporting
+// false for every other type in the file.
+func readServer(pkg *packages.Package, spec *ast.TypeSpec, imports *importSet) (*model.Service, bool) {
+	serverName := spec.Name.Name
+	if !strings.HasSuffix(serverName, "Server") ||
+		strings.Contains(serverName, "_") ||
+		strings.HasPrefix(serverName, "Unimplemented") ||
+		strings.HasPrefix(serverName, "Unsafe") {
+		return nil, false
+	}
+	astInt, ok := spec.Type.(*ast.InterfaceType)
+	if !ok {
+		return nil, false
+	}
+	obj := pkg.TypesInfo.Defs[spec.Name]
+	if obj == nil {
+		return nil, false
+	}
+	if _, ok := obj.Type().Underlying().(*types.Interface); !ok {
+		return nil, false
+	}
+
+	service := &model.Service{
+		Name:   shorten.TrimServiceName(serverName),
+		Server: serverName,
+	}
+
+	// walk the syntax rather than the type so methods keep their source order
+	for _, field := range astInt.Methods.List {
+		// embedded interfaces such as grpc.ServerStream have no names
+		if len(field.Names) == 0 {
+			continue
+		}
+		metName := field.Names[0].Name
+		if strings.HasPrefix(metName, "mustEmbedUnimplemented") {
+			continue
+		}
+		fn, ok := pkg.TypesInfo.Defs[field.Names[0]].(*types.Func)
+		if !ok {
+			continue
+		}
+		sig := fn.Type().(*types.Signature)
+
+		method := &model.Method{
+			Name:    metName,
+			Comment: goComment(field.Doc),
+		}
+		method.Args, method.Returns = signatureArgs(sig, imports)
+		readMessages(method, sig, imports)
+
+		service.Methods = append(service.Methods, method)
+	}
+
+	return service, true
+}
+
+// readMessages fills the stream kind and the message types of a handler
+// method from its signature.
+func readMessages(m *model.Method, sig *types.Signature, imports *importSet) {
+	var client, server bool
+	params := sig.Params()
+	for i := 0; i < params.Len(); i++ {
+		t := params.At(i).Type()
+		if !isStream(t) {
+			if ptr, ok := t.(*types.Pointer); ok && m.RequestArg == "" {
+				m.RequestArg = m.Args[i].Name
+				m.Request = imports.typeString(ptr.Elem())
+			}
+			continue
+		}
+
+		if recv := methodSignature(t, "Recv"); recv != nil && recv.Results().Len() > 0 {
+			client = true
+			m.Request = imports.typeString(elem(recv.Results().At(0).Type()))
+		}
+		if send := methodSignature(t, "Send"); send != nil && send.Params().Len() > 0 {
+			server = true
+			m.Response = imports.typeString(elem(send.Params().At(0).Type()))
+		}
+		if send := methodSignature(t, "SendAndClose"); send != nil && send.Params().Len() > 0 {
+			m.Response = imports.typeString(elem(send.Params().At(0).Type()))
+		}
+	}
+
+	m.Stream = streamKind(client, server)
+	if m.Stream == Unary && sig.Results().Len() > 0 {
+		m.Response = imports.typeString(elem(sig.Results().At(0).Type()))
+	}
+	if m.Stream == ClientStream || m.Stream == BidiStream {
+		m.RequestArg = ""
+	}
+}
+
+func methodSignature(t types.Type, name string) *types.Signature {
+	fn, _, _ := types.LookupFieldOrMethod(t, true, nil, name)
+	if fn, ok := fn.(*types.Func); ok {
+		return fn.Type().(*types.Signature)
+	}
+	return nil
+}
+
+func elem(t types.Type) types.Type {
+	if ptr, ok := t.(*types.Pointer); ok {
+		return ptr.Elem()
+	}
+	return t
+}
+
+func goComment(doc *ast.CommentGroup) string {
+	text := strings.TrimSpace(doc.Text())
+	if text == "" {
+		return ""
+	}
+	return "// " + strings.ReplaceAll(text, "\n", "\n// ")
+}
@@ -0,0 +1,60 @@
+package generator
+
+import (
+	_ "embed"
+	"strings"
+)
+
+//go:embed sample/impl
+var implSample string
+
+// ImplGen renders a struct implementing each interface of an IntGen.
+type ImplGen struct {
+	FileName string
+	Package  string
+	Domain   string
+	Imports  []*Import
+	Body     []*ImplBody
+	// Template names the template the file is rendered with.
+	Template string
+
+	origin origin
+}
+
+// ImplBody is an interface along with the layers its implementation
+// injects.
+type ImplBody struct {
+	*IntBody
+	Injectors []*Injector
+}
+
+func newImplGen(intFile *IntGen, layer *Layer) *ImplGen {
+	g := &ImplGen{
+		FileName: strings.TrimSuffix(intFile.FileName, ".go") + "_impl.go",
+		Package:  intFile.Package,
+		Domain:   intFile.Domain,
+		Imports:  append(append([]*Import{}, intFile.Imports...), layer.imports()...),
+		Template: layer.Template,
+		origin:   intFile.origin,
+	}
+	for _, body := range intFile.Body {
+		g.Body = append(g.Body, &ImplBody{IntBody: body, Injectors: layer.injectors(body.Name)})
+	}
+	return g
+}
+
+func (g *ImplGen) name() string {
+	return g.FileName
+}
+
+func (g *ImplGen) template() (string, string) {
+	return g.Template, implSample
+}
+
+func (g *ImplGen) from() origin {
+	return g.origin
+}
+
+func (g *ImplGen) render(r *run) ([]byte, error) {
+	return r.render(g.FileName, g.Template, implSample, g.Imports, g, g.origin.scaffolded())
+}
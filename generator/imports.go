@@ -0,0 +1,162 @@
+package generator
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/dotdak/go-templater/pkg/model"
+	"github.com/dotdak/go-templater/pkg/shorten"
+)
+
+// importSet collects the imports a generated file needs and hands out a
+// unique name for each of them.
+type importSet struct {
+	imports []*Import
+	aliases map[string]string // path -> alias
+	taken   map[string]bool
+}
+
+func newImportSet(reserved ...string) *importSet {
+	s := &importSet{
+		aliases: make(map[string]string),
+		taken:   make(map[string]bool),
+	}
+	for _, name := range reserved {
+		s.taken[name] = true
+	}
+	return s
+}
+
+// add registers the import and returns the name to qualify its identifiers with.
+func (s *importSet) add(importPath, name string) string {
+	if alias, ok := s.aliases[importPath]; ok {
+		return alias
+	}
+
+	alias := name
+	for i := 2; s.taken[alias]; i++ {
+		alias = fmt.Sprintf("%s%d", name, i)
+	}
+	s.taken[alias] = true
+	s.aliases[importPath] = alias
+
+	imp := &Import{Path: importPath}
+	if alias != path.Base(importPath) {
+		imp.Name = alias
+	}
+	s.imports = append(s.imports, imp)
+	return alias
+}
+
+func (s *importSet) qualifier(pkg *types.Package) string {
+	return s.add(pkg.Path(), pkg.Name())
+}
+
+func (s *importSet) typeString(t types.Type) string {
+	return types.TypeString(t, s.qualifier)
+}
+
+// list returns a copy of the registered imports followed by extra.
+func (s *importSet) list(extra ...*Import) []*Import {
+	out := make([]*Import, 0, len(s.imports)+len(extra))
+	out = append(out, s.imports...)
+	return append(out, extra...)
+}
+
+// modelImports returns the registered imports along with the names they
+// were given, sorted by path.
+func (s *importSet) modelImports() []*model.Import {
+	out := make([]*model.Import, 0, len(s.imports))
+	for _, imp := range s.imports {
+		out = append(out, &model.Import{Name: s.aliases[imp.Path], Path: imp.Path})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Path < out[j].Path
+	})
+	return out
+}
+
+// signatureArgs converts sig into model args, qualifying every type
+// through imports and naming unnamed parameters after their types.
+func signatureArgs(sig *types.Signature, imports *importSet) (args, returns []*model.Arg) {
+	used := make(map[string]bool)
+	params := sig.Params()
+	for i := 0; i < params.Len(); i++ {
+		v := params.At(i)
+		typ := imports.typeString(v.Type())
+		if sig.Variadic() && i == params.Len()-1 {
+			typ = "..." + imports.typeString(v.Type().(*types.Slice).Elem())
+		}
+
+		name := argName(v)
+		for j := 2; used[name]; j++ {
+			name = fmt.Sprintf("%s%d", argName(v), j)
+		}
+		used[name] = true
+
+		args = append(args, &model.Arg{Name: name, Type: typ})
+	}
+
+	results := sig.Results()
+	for i := 0; i < results.Len(); i++ {
+		returns = append(returns, &model.Arg{Type: imports.typeString(results.At(i).Type())})
+	}
+
+	return args, returns
+}
+
+func argName(v *types.Var) string {
+	if v.Name() != "" && v.Name() != "_" {
+		return v.Name()
+	}
+	if isStream(v.Type()) {
+		return "stream"
+	}
+
+	t := v.Type()
+	for {
+		switch x := t.(type) {
+		case *types.Pointer:
+			t = x.Elem()
+			continue
+		case *types.Slice:
+			t = x.Elem()
+			continue
+		case *types.Array:
+			t = x.Elem()
+			continue
+		case *types.Map:
+			t = x.Elem()
+			continue
+		}
+		break
+	}
+
+	name := "arg"
+	if named, ok := t.(*types.Named); ok {
+		name = shorten.Lookup(named.Obj().Name())
+	}
+	if token.IsKeyword(name) || strings.Contains(name, "_") {
+		name = "arg"
+	}
+	return name
+}
+
+// isStream reports whether t is a gRPC server stream, either the generated
+// Foo_BarServer interfaces or grpc.ServerStreamingServer and friends.
+func isStream(t types.Type) bool {
+	return hasMethod(t, "Send") || hasMethod(t, "Recv")
+}
+
+func hasMethod(t types.Type, name string) bool {
+	if _, ok := t.Underlying().(*types.Interface); !ok {
+		return false
+	}
+	obj, _, _ := types.LookupFieldOrMethod(t, true, nil, name)
+	_, ok := obj.(*types.Func)
+	return ok
+}
@@ -0,0 +1,52 @@
+package generator
+
+import _ "embed"
+
+//go:embed sample/interface
+var interfaceSample string
+
+type IntGen struct {
+	FileName string
+	Package  string
+	Domain   string
+	Imports  []*Import
+	Body     []*IntBody
+
+	source string
+	origin origin
+}
+
+// forLayer places the interfaces in layer.
+func (g *IntGen) forLayer(layer *Layer) *IntGen {
+	return &IntGen{
+		FileName: layer.fileName(g.source),
+		Package:  getPackageFromDir(layer.dir),
+		Domain:   layer.Name,
+		Imports:  g.Imports,
+		Body:     g.Body,
+		source:   g.source,
+		origin:   g.origin,
+	}
+}
+
+type IntBody struct {
+	Name    string
+	Comment string
+	Methods []*MethodBody
+}
+
+func (g *IntGen) name() string {
+	return g.FileName
+}
+
+func (g *IntGen) template() (string, string) {
+	return "interface", interfaceSample
+}
+
+func (g *IntGen) from() origin {
+	return g.origin
+}
+
+func (g *IntGen) render(r *run) ([]byte, error) {
+	return r.render(g.FileName, "interface", interfaceSample, g.Imports, g, g.origin.generated())
+}
@@ -0,0 +1,177 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/dotdak/go-templater/pkg/modpath"
+	"github.com/dotdak/go-templater/pkg/shorten"
+)
+
+var ErrLayers = errors.New("the first layer must inject another one")
+
+// Layer is one step of the chain generated for every service, such as
+// Handler -> UseCase -> Repository. The first layer implements the gRPC
+// server, every other one gets an interface and, rendered with Template,
+// an implementation. The gotem command reads them from the layers key of
+// its config file:
+//
+//	layers:
+//	  - name: Handler
+//	    out: ./handlers/v1
+//	  - name: UseCase
+//	    out: ./usecases
+//	    inject: [Repository]
+//	  - name: Repository
+//	    out: ./repositories
+type Layer struct {
+	Name string `yaml:"name"`
+	Out  string `yaml:"out"`
+	// Template defaults to domain for the first layer and impl for the
+	// others, none skips the implementation.
+	Template string `yaml:"template"`
+	// Inject names the layers this one is built on, the next one when
+	// left out and none when empty.
+	Inject []string `yaml:"inject"`
+
+	dir        string
+	importPath string
+	alias      string
+	injects    []*Layer
+}
+
+// Alias is the name the layer's package is imported with, layers sharing
+// a directory share the alias of the first of them.
+func (l *Layer) Alias() string {
+	return l.alias
+}
+
+// fileName is the file generated for source in the layer.
+func (l *Layer) fileName(source string) string {
+	return fmt.Sprintf("%s/%s_%s.go", l.dir, shorten.TrimFileName(source), strings.ToLower(l.Name))
+}
+
+// resolveLayers returns copies of layers with the defaults, output
+// directories and injections filled in, leaving the caller's untouched.
+func resolveLayers(resolver *modpath.Resolver, given []*Layer) ([]*Layer, error) {
+	if len(given) == 0 {
+		given = []*Layer{
+			{Name: "Handler", Out: "./handlers/v1"},
+			{Name: "Service", Out: "./services"},
+		}
+	}
+	layers := make([]*Layer, len(given))
+	for i, l := range given {
+		layers[i] = &Layer{Name: l.Name, Out: l.Out, Template: l.Template, Inject: l.Inject}
+	}
+
+	byName := make(map[string]*Layer, len(layers))
+	aliases := make(map[string]string, len(layers))
+	for i, l := range layers {
+		if l.Name == "" || l.Out == "" {
+			return nil, fmt.Errorf("layer %d: name and out are required", i)
+		}
+		if _, ok := byName[l.Name]; ok {
+			return nil, fmt.Errorf("layer %s: declared twice", l.Name)
+		}
+		byName[l.Name] = l
+
+		if l.Template == "" {
+			l.Template = "impl"
+			if i == 0 {
+				l.Template = "domain"
+			}
+		}
+
+		var err error
+		if l.dir, err = filepath.Abs(l.Out); err != nil {
+			return nil, err
+		}
+		if l.importPath, err = resolver.ImportPath(l.dir); err != nil {
+			return nil, fmt.Errorf("resolve layer %s: %w", l.Name, err)
+		}
+		if _, ok := aliases[l.dir]; !ok {
+			aliases[l.dir] = shorten.Lookup(l.Name)
+		}
+		l.alias = aliases[l.dir]
+	}
+
+	for i, l := range layers {
+		names := l.Inject
+		if names == nil && i+1 < len(layers) {
+			names = []string{layers[i+1].Name}
+		}
+		for _, name := range names {
+			inject, ok := byName[name]
+			if !ok {
+				return nil, fmt.Errorf("layer %s: injects unknown layer %s", l.Name, name)
+			}
+			if inject == layers[0] {
+				return nil, fmt.Errorf("layer %s: can't inject the first layer", l.Name)
+			}
+			l.injects = append(l.injects, inject)
+		}
+	}
+	if len(layers[0].injects) == 0 {
+		return nil, ErrLayers
+	}
+
+	return layers, nil
+}
+
+// injectors are the fields l holds for the service called serviceName.
+func (l *Layer) injectors(serviceName string) []*Injector {
+	injectors := make([]*Injector, 0, len(l.injects))
+	for _, inject := range l.injects {
+		name := serviceName + inject.Name
+		injector := &Injector{Name: name, Alias: shorten.LowerFirst(name)}
+		if inject.dir != l.dir {
+			injector.Package = inject.Alias()
+		}
+		injectors = append(injectors, injector)
+	}
+	return injectors
+}
+
+// imports are the packages of the layers l injects.
+func (l *Layer) imports() []*Import {
+	var imports []*Import
+	seen := map[string]bool{l.importPath: true}
+	for _, inject := range l.injects {
+		if seen[inject.importPath] {
+			continue
+		}
+		seen[inject.importPath] = true
+		imports = append(imports, &Import{Name: inject.Alias(), Path: inject.importPath})
+	}
+	return imports
+}
+
+// chainLayers turns what was read from the input into the files of every
+// layer: the handler files for the first one, an interface and an
+// implementation per service file for the others.
+func chainLayers(layers []*Layer, domainFiles []*DomainGenerator, intFiles []*IntGen, impl bool) []file {
+	handler := layers[0]
+
+	var files []file
+	for _, fi := range domainFiles {
+		fi.Imports = append(fi.Imports, handler.imports()...)
+		for _, body := range fi.Body {
+			body.Injectors = handler.injectors(body.ServiceName)
+		}
+		files = append(files, fi)
+	}
+
+	for _, fi := range intFiles {
+		for _, layer := range layers[1:] {
+			intFile := fi.forLayer(layer)
+			files = append(files, intFile)
+			if impl && layer.Template != "none" {
+				files = append(files, newImplGen(intFile, layer))
+			}
+		}
+	}
+	return files
+}
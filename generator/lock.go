@@ -0,0 +1,165 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/dotdak/go-templater/pkg/manifest"
+	"github.com/dotdak/go-templater/version"
+)
+
+// lock is the manifest a run reads the files it generated before from and
+// records the ones it generates in.
+type lock struct {
+	name   string
+	before []byte
+	m      *manifest.Manifest
+	// layout hashes the layers the files are rendered with.
+	layout string
+	// source is where the input is read from, as recorded.
+	source string
+}
+
+// readLock reads the manifest of Options.Manifest through the sink, empty
+// when it doesn't exist yet.
+func (r *run) readLock(layers []*Layer) error {
+	if r.opts.Manifest == "" {
+		return nil
+	}
+	name, err := filepath.Abs(r.opts.Manifest)
+	if err != nil {
+		return err
+	}
+
+	l := &lock{name: name, m: manifest.New(), layout: layout(layers), source: source(filepath.Dir(name), r.opts.Input)}
+	l.before, err = r.opts.Sink.ReadFile(name)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		l.before = nil
+	case err != nil:
+		return err
+	default:
+		if l.m, err = manifest.Decode(l.before); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	r.lock = l
+	return nil
+}
+
+// source is where in reads from as the manifest records it, its path
+// relative to dir unless a module query.
+func source(dir string, in Source) string {
+	ps, ok := in.(pathSource)
+	if !ok {
+		return ""
+	}
+	if strings.Contains(ps.path, "@") {
+		return ps.path
+	}
+	p, recursive := strings.CutSuffix(ps.path, "/...")
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return ps.path
+	}
+	rel, err := filepath.Rel(dir, abs)
+	if err != nil {
+		return ps.path
+	}
+	rel = filepath.ToSlash(rel)
+	if recursive {
+		rel += "/..."
+	}
+	return rel
+}
+
+// layout is what of layers ends up in the files, the version of gotem
+// included.
+func layout(layers []*Layer) string {
+	var b strings.Builder
+	b.WriteString(version.Version)
+	for _, l := range layers {
+		fmt.Fprintf(&b, "\n%s %s %s %s", l.Name, l.importPath, l.alias, l.Template)
+		for _, inject := range l.injects {
+			fmt.Fprintf(&b, " %s", inject.Name)
+		}
+	}
+	return b.String()
+}
+
+// entry is the manifest file f is recorded as, but for its hash. It is nil
+// without a manifest.
+func (r *run) entry(f file) (*manifest.File, error) {
+	if r.lock == nil {
+		return nil, nil
+	}
+	name, embedded := f.template()
+	text, err := r.templates.text(name, embedded)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(filepath.Dir(r.lock.name), f.name())
+	if err != nil {
+		return nil, err
+	}
+
+	o := f.from()
+	return &manifest.File{
+		Path:      filepath.ToSlash(rel),
+		Input:     o.path,
+		InputHash: o.hash,
+		Source:    r.lock.source,
+		Template:  manifest.Sum([]byte(r.lock.layout + "\n" + text))[:12],
+	}, nil
+}
+
+// recorded returns the manifest file recorded for entry when existing is
+// what the run would write: the input, template and layers didn't change
+// since and the file wasn't edited. The entry is recorded again when read
+// through another source. Overwrite still replaces the merged
+// files it doesn't merge into.
+func (r *run) recorded(entry *manifest.File, existing []byte, exists bool) *manifest.File {
+	if entry == nil || !exists {
+		return nil
+	}
+	old := r.lock.m.Lookup(entry.Path)
+	if old == nil || old.InputHash != entry.InputHash || old.Source != entry.Source || old.Template != entry.Template || old.Hash != manifest.Sum(existing) {
+		return nil
+	}
+	if old.Merged && r.opts.Overwrite && !r.opts.Merge && (r.opts.Force || !scaffold(existing, nil)) {
+		return nil
+	}
+	return old
+}
+
+// written is entry recording content, nil without a manifest.
+func written(entry *manifest.File, content []byte, merged bool) *manifest.File {
+	if entry == nil {
+		return nil
+	}
+	e := *entry
+	e.Hash = manifest.Sum(content)
+	e.Merged = merged
+	return &e
+}
+
+// lockChange records the files of changes in the manifest, the files of
+// other runs kept. ok is false without a manifest.
+func (r *run) lockChange(changes []Change) (c Change, ok bool, err error) {
+	if r.lock == nil {
+		return Change{}, false, nil
+	}
+	for _, c := range changes {
+		if c.entry != nil {
+			r.lock.m.Put(c.entry)
+		}
+	}
+	after, err := r.lock.m.Encode()
+	if err != nil {
+		return Change{}, false, err
+	}
+	return Change{Name: r.lock.name, Before: r.lock.before, After: after}, true, nil
+}
@@ -0,0 +1,489 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// mergeReport lists what merging a generated file into an existing one did.
+type mergeReport struct {
+	Added   []string
+	Changed []string
+	// Stale, Deprecated and Moved list the methods no longer generated,
+	// by what was done with them.
+	Stale      []string
+	Deprecated []string
+	Moved      []string
+	// Removed holds the stale declarations cut out of the file by
+	// StaleMove, ready to be appended to the _removed.go file.
+	Removed []string
+}
+
+// mergeSource adds the declarations and interface methods of generated that
+// existing lacks. Existing code is kept byte for byte but for the imports
+// the added code needs; declarations whose signature differs from the
+// generated one are only reported, those no longer generated at all are
+// handled according to stale. The added code qualifies the packages with
+// the names existing imports them with.
+func mergeSource(fileName string, existing, generated []byte, stale string) ([]byte, *mergeReport, error) {
+	fset := token.NewFileSet()
+	oldFile, err := parser.ParseFile(fset, "existing.go", existing, parser.ParseComments)
+	if err != nil {
+		return nil, nil, err
+	}
+	generated, qualified, err := alignImports(oldFile, generated)
+	if err != nil {
+		return nil, nil, err
+	}
+	newFile, err := parser.ParseFile(fset, "generated.go", generated, parser.ParseComments)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		report  = &mergeReport{}
+		edits   []edit
+		added   []ast.Node
+		oldDecl = indexDecls(oldFile)
+		offset  = func(pos token.Pos) int { return fset.File(pos).Offset(pos) }
+		text    = func(src []byte, node ast.Node, doc *ast.CommentGroup) string {
+			start := node.Pos()
+			if doc != nil {
+				start = doc.Pos()
+			}
+			return string(src[offset(start):offset(node.End())])
+		}
+	)
+
+	for _, decl := range newFile.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			continue
+		}
+		key := declKey(decl)
+		old, ok := oldDecl[key]
+		if !ok {
+			edits = append(edits, edit{offset: len(existing), text: "\n" + text(generated, decl, declDoc(decl)) + "\n"})
+			report.Added = append(report.Added, key)
+			added = append(added, decl)
+			continue
+		}
+
+		switch x := decl.(type) {
+		case *ast.FuncDecl:
+			if signature(x.Recv, x.Type) != signature(old.(*ast.FuncDecl).Recv, old.(*ast.FuncDecl).Type) {
+				report.Changed = append(report.Changed, key)
+			}
+		case *ast.GenDecl:
+			oldInt, newInt := interfaceOf(old), interfaceOf(x)
+			if oldInt == nil || newInt == nil {
+				continue
+			}
+			oldMethods := make(map[string]*ast.Field)
+			for _, m := range oldInt.Methods.List {
+				if len(m.Names) > 0 {
+					oldMethods[m.Names[0].Name] = m
+				}
+			}
+			for _, m := range newInt.Methods.List {
+				if len(m.Names) == 0 {
+					continue
+				}
+				name := key + "." + m.Names[0].Name
+				oldMethod, ok := oldMethods[m.Names[0].Name]
+				if !ok {
+					at, indent := offset(oldInt.Methods.Closing), "\n\t"
+					if at > 0 && existing[at-1] == '\n' {
+						indent = "\t"
+					}
+					edits = append(edits, edit{offset: at, text: indent + text(generated, m, m.Doc) + "\n"})
+					report.Added = append(report.Added, name)
+					added = append(added, m)
+					continue
+				}
+				if signature(nil, oldMethod.Type.(*ast.FuncType)) != signature(nil, m.Type.(*ast.FuncType)) {
+					report.Changed = append(report.Changed, name)
+				}
+			}
+		}
+	}
+
+	edits = append(edits, staleEdits(fset, existing, oldFile, newFile, stale, report)...)
+	if len(edits) == 0 {
+		return existing, report, nil
+	}
+
+	// the inserted code comes from gofmt'ed output already, formatting the
+	// merged file again would touch hand written code
+	merged := applyEdits(existing, edits)
+	if imports := missingImports(oldFile, qualified, added); len(imports) > 0 {
+		merged, err = editImports(fileName, merged, func(fset *token.FileSet, f *ast.File) {
+			for _, imp := range imports {
+				astutil.AddNamedImport(fset, f, imp.Name, imp.Path)
+			}
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(report.Moved) > 0 {
+		// moving methods out may leave imports unused, formatting would
+		// drop them but touch hand written code too
+		used := qualifiers(oldFile)
+		merged, err = editImports(fileName, merged, func(fset *token.FileSet, f *ast.File) {
+			still := qualifiers(f)
+			var unused []*ast.ImportSpec
+			for _, imp := range f.Imports {
+				if name := importName(imp); used[name] && !still[name] {
+					unused = append(unused, imp)
+				}
+			}
+			for _, imp := range unused {
+				path, _ := strconv.Unquote(imp.Path.Value)
+				astutil.DeleteNamedImport(fset, f, nameOf(imp), path)
+			}
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), fileName, merged, parser.ParseComments); err != nil {
+		return nil, nil, err
+	}
+	return merged, report, nil
+}
+
+// edit replaces existing[offset:end] with text, end is zero for inserts.
+type edit struct {
+	offset int
+	end    int
+	text   string
+}
+
+func applyEdits(src []byte, edits []edit) []byte {
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].offset < edits[j].offset
+	})
+
+	var b bytes.Buffer
+	last := 0
+	for _, e := range edits {
+		b.Write(src[last:e.offset])
+		b.WriteString(e.text)
+		last = e.offset
+		if e.end > last {
+			last = e.end
+		}
+	}
+	b.Write(src[last:])
+	return b.Bytes()
+}
+
+// declKey identifies a top level declaration across the two files.
+func declKey(decl ast.Decl) string {
+	switch x := decl.(type) {
+	case *ast.FuncDecl:
+		if x.Recv != nil && len(x.Recv.List) > 0 {
+			return exprString(x.Recv.List[0].Type) + "." + x.Name.Name
+		}
+		return x.Name.Name
+	case *ast.GenDecl:
+		var names []string
+		for _, spec := range x.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				names = append(names, s.Name.Name)
+			case *ast.ValueSpec:
+				// blank assertions like var _ pkg.Server = new(Impl) only
+				// differ in their types and values
+				names = append(names, exprString(s.Type))
+				for i, name := range s.Names {
+					names = append(names, name.Name)
+					if i < len(s.Values) {
+						names = append(names, exprString(s.Values[i]))
+					}
+				}
+			}
+		}
+		return x.Tok.String() + " " + strings.Join(names, ",")
+	}
+	return ""
+}
+
+func declDoc(decl ast.Decl) *ast.CommentGroup {
+	switch x := decl.(type) {
+	case *ast.FuncDecl:
+		return x.Doc
+	case *ast.GenDecl:
+		return x.Doc
+	}
+	return nil
+}
+
+func indexDecls(f *ast.File) map[string]ast.Decl {
+	index := make(map[string]ast.Decl, len(f.Decls))
+	for _, decl := range f.Decls {
+		index[declKey(decl)] = decl
+	}
+	return index
+}
+
+func interfaceOf(decl ast.Decl) *ast.InterfaceType {
+	gen, ok := decl.(*ast.GenDecl)
+	if !ok || gen.Tok != token.TYPE || len(gen.Specs) != 1 {
+		return nil
+	}
+	it, _ := gen.Specs[0].(*ast.TypeSpec).Type.(*ast.InterfaceType)
+	return it
+}
+
+// signature prints a function type without parameter names, so renaming
+// a parameter by hand doesn't count as a change.
+func signature(recv *ast.FieldList, fn *ast.FuncType) string {
+	fields := func(list *ast.FieldList) string {
+		if list == nil {
+			return ""
+		}
+		var types []string
+		for _, f := range list.List {
+			n := len(f.Names)
+			if n == 0 {
+				n = 1
+			}
+			for i := 0; i < n; i++ {
+				types = append(types, exprString(f.Type))
+			}
+		}
+		return strings.Join(types, ",")
+	}
+	return fmt.Sprintf("(%s)(%s)(%s)", fields(recv), fields(fn.Params), fields(fn.Results))
+}
+
+func exprString(expr ast.Expr) string {
+	if expr == nil {
+		return ""
+	}
+	var b bytes.Buffer
+	printer.Fprint(&b, token.NewFileSet(), expr)
+	return b.String()
+}
+
+// alignImports rewrites the qualifiers of generated to the names existing
+// imports the same packages with, and renames the packages existing lacks
+// when their name is taken there. It returns the rewritten source along
+// with the import each qualifier stands for.
+func alignImports(existing *ast.File, generated []byte) ([]byte, map[string]*Import, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "generated.go", generated, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	have := make(map[string]string, len(existing.Imports))
+	taken := make(map[string]bool, len(existing.Imports))
+	for _, imp := range existing.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		have[path] = ""
+		if imp.Name != nil {
+			have[path] = imp.Name.Name
+		}
+		taken[importName(imp)] = true
+	}
+
+	renames := make(map[string]string)
+	qualified := make(map[string]*Import)
+	for _, imp := range f.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		name := importName(imp)
+		if name == "_" || name == "." {
+			continue
+		}
+		target := &Import{Path: path}
+		if imp.Name != nil {
+			target.Name = name
+		}
+		alias, ok := have[path]
+		switch {
+		case ok && alias != "":
+			target.Name = alias
+		case ok:
+			// imported under the package name, the one generated uses
+		default:
+			for i := 2; taken[qualifier(target)]; i++ {
+				target.Name = fmt.Sprintf("%s%d", name, i)
+			}
+			taken[qualifier(target)] = true
+		}
+		renames[name] = qualifier(target)
+		qualified[qualifier(target)] = target
+	}
+
+	var edits []edit
+	ast.Inspect(f, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		// unresolved identifiers are the package names
+		if id, ok := sel.X.(*ast.Ident); ok && id.Obj == nil {
+			if to, ok := renames[id.Name]; ok && to != id.Name {
+				edits = append(edits, edit{offset: fset.Position(id.Pos()).Offset, end: fset.Position(id.End()).Offset, text: to})
+			}
+		}
+		return true
+	})
+	return applyEdits(generated, edits), qualified, nil
+}
+
+// importName is the name imp is referred to with, guessed from its path
+// when not given.
+func importName(imp *ast.ImportSpec) string {
+	if imp.Name != nil {
+		return imp.Name.Name
+	}
+	path, _ := strconv.Unquote(imp.Path.Value)
+	return assumedName(path)
+}
+
+// nameOf is the name imp is given, empty when none.
+func nameOf(imp *ast.ImportSpec) string {
+	if imp.Name == nil {
+		return ""
+	}
+	return imp.Name.Name
+}
+
+// qualifiers returns the package names node qualifies identifiers with,
+// the identifiers resolving to nothing in the file.
+func qualifiers(node ast.Node) map[string]bool {
+	names := make(map[string]bool)
+	ast.Inspect(node, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok && id.Obj == nil {
+				names[id.Name] = true
+			}
+		}
+		return true
+	})
+	return names
+}
+
+// qualifier is the name imp is referred to with.
+func qualifier(imp *Import) string {
+	if imp.Name != "" {
+		return imp.Name
+	}
+	return assumedName(imp.Path)
+}
+
+// missingImports returns the imports the added nodes qualify identifiers
+// with that existing lacks, sorted by path.
+func missingImports(existing *ast.File, qualified map[string]*Import, added []ast.Node) []*Import {
+	have := make(map[string]bool, len(existing.Imports))
+	for _, imp := range existing.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		have[path] = true
+	}
+
+	var imports []*Import
+	for _, node := range added {
+		for name := range qualifiers(node) {
+			if imp, ok := qualified[name]; ok && !have[imp.Path] {
+				have[imp.Path] = true
+				imports = append(imports, imp)
+			}
+		}
+	}
+	sort.Slice(imports, func(i, j int) bool {
+		return imports[i].Path < imports[j].Path
+	})
+	return imports
+}
+
+// editImports hands the parsed src to fn and reprints the import
+// declarations it changed, leaving the rest of src byte for byte.
+func editImports(fileName string, src []byte, fn func(fset *token.FileSet, f *ast.File)) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, fileName, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	offset := func(pos token.Pos) int { return fset.Position(pos).Offset }
+
+	before := importDecls(f)
+	fn(fset, f)
+	after := importDecls(f)
+
+	var edits []edit
+	insertAt := offset(f.Name.End())
+	for _, gen := range before {
+		start, end := offset(gen.Pos()), offset(gen.End())
+		if !contains(after, gen) {
+			// drop the line along with the declaration
+			if end < len(src) && src[end] == '\n' {
+				end++
+			}
+			edits = append(edits, edit{offset: start, end: end})
+			continue
+		}
+		insertAt = end
+		text, err := printImports(fset, f, gen)
+		if err != nil {
+			return nil, err
+		}
+		if text != string(src[start:end]) {
+			edits = append(edits, edit{offset: start, end: end, text: text})
+		}
+	}
+	for _, gen := range after {
+		if contains(before, gen) {
+			continue
+		}
+		text, err := printImports(fset, f, gen)
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, edit{offset: insertAt, text: "\n\n" + text})
+	}
+	return applyEdits(src, edits), nil
+}
+
+func importDecls(f *ast.File) []*ast.GenDecl {
+	var decls []*ast.GenDecl
+	for _, decl := range f.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			decls = append(decls, gen)
+		}
+	}
+	return decls
+}
+
+func contains(decls []*ast.GenDecl, gen *ast.GenDecl) bool {
+	for _, d := range decls {
+		if d == gen {
+			return true
+		}
+	}
+	return false
+}
+
+// printImports prints gen along with the comments inside it, its doc
+// comment left out.
+func printImports(fset *token.FileSet, f *ast.File, gen *ast.GenDecl) (string, error) {
+	decl := *gen
+	decl.Doc = nil
+	var b bytes.Buffer
+	if err := format.Node(&b, fset, &printer.CommentedNode{Node: &decl, Comments: f.Comments}); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,290 @@
+package generator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMergeSource(t *testing.T) {
+	tests := []struct {
+		name      string
+		existing  string
+		generated string
+		stale     string
+		want      string
+		added     []string
+	}{
+		{
+			name: "up to date",
+			existing: `package foo
+
+func (h *Handler) Get() {
+	return  // hand written
+}
+`,
+			generated: `package foo
+
+func (h *Handler) Get() {
+}
+`,
+			want: `package foo
+
+func (h *Handler) Get() {
+	return  // hand written
+}
+`,
+		},
+		{
+			name: "existing aliases",
+			existing: `package foo
+
+import (
+	"context"
+
+	pb "example.com/fx/api/foo/v1"
+)
+
+var _ pb.FooServiceServer = (*Handler)(nil)
+
+func (h *Handler) Get(ctx context.Context, req *pb.GetRequest) (*pb.GetResponse, error) {
+	return  &pb.GetResponse{}, nil
+}
+`,
+			generated: `package foo
+
+import (
+	"context"
+	"io"
+
+	foov1 "example.com/fx/api/foo/v1"
+	"example.com/fx/services"
+)
+
+var _ foov1.FooServiceServer = (*Handler)(nil)
+
+func (h *Handler) Get(ctx context.Context, req *foov1.GetRequest) (*foov1.GetResponse, error) {
+	return nil, nil
+}
+
+// List lists.
+func (h *Handler) List(ctx context.Context, req *foov1.ListRequest) (*foov1.ListResponse, error) {
+	var _ io.Reader
+	return nil, nil
+}
+`,
+			want: `package foo
+
+import (
+	"context"
+	"io"
+
+	pb "example.com/fx/api/foo/v1"
+)
+
+var _ pb.FooServiceServer = (*Handler)(nil)
+
+func (h *Handler) Get(ctx context.Context, req *pb.GetRequest) (*pb.GetResponse, error) {
+	return  &pb.GetResponse{}, nil
+}
+
+// List lists.
+func (h *Handler) List(ctx context.Context, req *pb.ListRequest) (*pb.ListResponse, error) {
+	var _ io.Reader
+	return nil, nil
+}
+`,
+			added: []string{"*Handler.List"},
+		},
+		{
+			name: "taken name",
+			existing: `package foo
+
+import services "example.com/other/services"
+
+var _ = services.New
+`,
+			generated: `package foo
+
+import "example.com/fx/services"
+
+func New() *services.Service {
+	return services.New()
+}
+`,
+			want: `package foo
+
+import (
+	services "example.com/other/services"
+	services2 "example.com/fx/services"
+)
+
+var _ = services.New
+
+func New() *services2.Service {
+	return services2.New()
+}
+`,
+			added: []string{"New"},
+		},
+		{
+			name: "no imports",
+			existing: `package foo
+
+type Service interface {
+	// Get gets.
+	Get()
+}
+`,
+			generated: `package foo
+
+import "context"
+
+type Service interface {
+	// Get gets.
+	Get()
+	List(ctx context.Context)
+}
+`,
+			want: `package foo
+
+import "context"
+
+type Service interface {
+	// Get gets.
+	Get()
+	List(ctx context.Context)
+}
+`,
+			added: []string{"type Service.List"},
+		},
+		{
+			name: "stale deprecated",
+			existing: `package foo
+
+func (h *Handler) Get() {}
+
+// Old is gone.
+func (h *Handler) Old() {}
+`,
+			generated: `package foo
+
+func (h *Handler) Get() {}
+`,
+			stale: StaleDeprecate,
+			want: `package foo
+
+func (h *Handler) Get() {}
+
+// Old is gone.
+//
+// Deprecated: removed from proto.
+func (h *Handler) Old() {}
+`,
+		},
+		{
+			name: "stale moved",
+			existing: `package foo
+
+import (
+	"errors"
+	"fmt"
+)
+
+func (h *Handler) Get() error {
+	return  fmt.Errorf("get")
+}
+
+// Old is gone.
+func (h *Handler) Old() error {
+	return errors.New("old")
+}
+`,
+			generated: `package foo
+
+func (h *Handler) Get() error {
+	return nil
+}
+`,
+			stale: StaleMove,
+			want: `package foo
+
+import (
+	"fmt"
+)
+
+func (h *Handler) Get() error {
+	return  fmt.Errorf("get")
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stale := tt.stale
+			if stale == "" {
+				stale = StaleReport
+			}
+			got, report, err := mergeSource("foo.go", []byte(tt.existing), []byte(tt.generated), stale)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("merged:\n%s\nwant:\n%s", got, tt.want)
+			}
+			if !reflect.DeepEqual(report.Added, tt.added) {
+				t.Errorf("added %q, want %q", report.Added, tt.added)
+			}
+		})
+	}
+}
+
+func TestRemovedSource(t *testing.T) {
+	existing := `package foo
+
+import (
+	"errors"
+	"fmt"
+)
+`
+	decls := []string{`// Old is gone.
+func (h *Handler) Old() error {
+	return  errors.New("old")
+}`}
+	want := `// Methods whose RPC was removed from the proto, moved here by gotem.
+
+package foo
+
+import "errors"
+
+// Old is gone.
+func (h *Handler) Old() error {
+	return  errors.New("old")
+}
+`
+	got, err := removedSource("foo.go", []byte(existing), nil, decls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// a second move appends to the file, importing what it lacks
+	decls = []string{`func (h *Handler) Older() error {
+	return fmt.Errorf("older")
+}`}
+	want += `
+func (h *Handler) Older() error {
+	return fmt.Errorf("older")
+}
+`
+	want = strings.Replace(want, `import "errors"`, "import (\n\t\"errors\"\n\t\"fmt\"\n)", 1)
+	if got, err = removedSource("foo.go", []byte(existing), got, decls); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,226 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/types"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/dotdak/go-templater/pkg/model"
+)
+
+// ModelFile reads a model written by gotem inspect.
+func ModelFile(path string) Source {
+	return pathSource{path: path, SourceFunc: func(context.Context, Env) ([]*model.File, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		m, err := model.Decode(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return m.Files, nil
+	}}
+}
+
+// Model generates from m as it is.
+func Model(m *model.Model) Source {
+	return SourceFunc(func(context.Context, Env) ([]*model.File, error) {
+		return m.Files, nil
+	})
+}
+
+// newGenerators builds the handler and interface files of every input file
+// with services. Imports clashing with the layer aliases are renamed.
+func newGenerators(layers []*Layer, files []*model.File) ([]*DomainGenerator, []*IntGen) {
+	var domainFiles []*DomainGenerator
+	var intFiles []*IntGen
+	for _, f := range files {
+		if len(f.Services) == 0 {
+			continue
+		}
+
+		imports := newImports(layers)
+		renames := make(map[string]string)
+		for _, imp := range f.Imports {
+			if alias := imports.add(imp.Path, imp.Name); alias != imp.Name {
+				renames[imp.Name] = alias
+			}
+		}
+		ctxType := imports.add("context", "context") + ".Context"
+
+		fileName := path.Base(f.Path)
+		domainFile := newDomainGenerator(layers[0], fileName, imports.add(f.GoPackage, f.GoName))
+		taken := make(map[string]bool, len(imports.taken)+len(bodyNames))
+		for name := range imports.taken {
+			taken[name] = true
+		}
+		for _, name := range bodyNames {
+			taken[name] = true
+		}
+		domainFile.ImportPackage = f.GoPackage
+		intFile := newIntGen(fileName)
+		domainFile.origin = newOrigin(f)
+		intFile.origin = domainFile.origin
+		for _, service := range f.Services {
+			domainBody := &DomainBody{
+				ServiceName: service.Name,
+				Server:      service.Server,
+				Comment:     strings.TrimPrefix(service.Comment, "// "),
+			}
+			intBody := &IntBody{
+				Name:    service.Name,
+				Comment: service.Comment,
+			}
+			for _, m := range service.Methods {
+				methodBody := newMethodBody(m, renames, ctxType, taken)
+				domainBody.Methods = append(domainBody.Methods, methodBody)
+				intBody.Methods = append(intBody.Methods, methodBody)
+			}
+			domainFile.Body = append(domainFile.Body, domainBody)
+			intFile.Body = append(intFile.Body, intBody)
+		}
+
+		setImports(domainFile, intFile, imports)
+		domainFiles = append(domainFiles, domainFile)
+		intFiles = append(intFiles, intFile)
+	}
+	return domainFiles, intFiles
+}
+
+// bodyNames are the names the method bodies of the templates refer to on
+// top of the imports of the file, which arguments named alike would shadow.
+var bodyNames = []string{
+	"errors", "io", "status", "codes", "h", "s",
+	"ctx", "cancel", "in", "out", "res", "err", "errc", "recvErr", "stream",
+}
+
+// newMethodBody converts m, renaming the args whose name is taken in the
+// generated file.
+func newMethodBody(m *model.Method, renames map[string]string, ctxType string, taken map[string]bool) *MethodBody {
+	methodBody := &MethodBody{
+		Name:         m.Name,
+		Comment:      m.Comment,
+		Stream:       m.Stream,
+		RequestArg:   m.RequestArg,
+		RequestType:  requalify(m.Request, renames),
+		ResponseType: requalify(m.Response, renames),
+		Args:         newArgs(m.Args, renames),
+		Returns:      newArgs(m.Returns, renames),
+	}
+	methodBody.RequestArg = renameArgs(methodBody.Args, m.RequestArg, ctxType, taken)
+	methodBody.setServiceSignature(ctxType)
+	return methodBody
+}
+
+// renameArgs numbers the args named as taken, as signatureArgs does
+// duplicates, and returns the new name of requestArg. The context and the
+// stream keep their names, the bodies refer to them by these.
+func renameArgs(args []*Args, requestArg, ctxType string, taken map[string]bool) string {
+	used := make(map[string]bool, len(args))
+	for _, arg := range args {
+		used[arg.Alias] = true
+	}
+
+	renamed, found := requestArg, false
+	for _, arg := range args {
+		isRequest := !found && arg.Alias == requestArg
+		found = found || isRequest
+		switch {
+		case !taken[arg.Alias]:
+			continue
+		case arg.Alias == "ctx" && arg.Type == ctxType, arg.Alias == "stream" && !isRequest:
+			continue
+		}
+		name := arg.Alias
+		for j := 2; taken[name] || used[name]; j++ {
+			name = fmt.Sprintf("%s%d", arg.Alias, j)
+		}
+		used[name] = true
+		if isRequest {
+			renamed = name
+		}
+		arg.Alias = name
+	}
+	return renamed
+}
+
+func newArgs(args []*model.Arg, renames map[string]string) []*Args {
+	out := make([]*Args, 0, len(args))
+	for _, arg := range args {
+		out = append(out, &Args{Alias: arg.Name, Type: requalify(arg.Type, renames)})
+	}
+	return out
+}
+
+// requalify renames the package qualifiers of typ.
+func requalify(typ string, renames map[string]string) string {
+	if len(renames) == 0 {
+		return typ
+	}
+	variadic := strings.HasPrefix(typ, "...")
+	expr, err := parser.ParseExpr(strings.TrimPrefix(typ, "..."))
+	if err != nil {
+		return typ
+	}
+
+	ast.Inspect(expr, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if x, ok := sel.X.(*ast.Ident); ok {
+			if alias, ok := renames[x.Name]; ok {
+				x.Name = alias
+			}
+		}
+		return false
+	})
+	if variadic {
+		return "..." + types.ExprString(expr)
+	}
+	return types.ExprString(expr)
+}
+
+func getPackageFromDir(dir string) string {
+	parts := strings.Split(dir, "/")
+	return parts[len(parts)-1]
+}
+
+func newDomainGenerator(handler *Layer, fileName, servicePackage string) *DomainGenerator {
+	return &DomainGenerator{
+		FileName:       handler.fileName(fileName),
+		Package:        getPackageFromDir(handler.dir),
+		ServicePackage: servicePackage,
+		Domain:         handler.Name,
+		Template:       handler.Template,
+	}
+}
+
+// newIntGen returns the interface file of fileName, placed in each layer by
+// chainLayers.
+func newIntGen(fileName string) *IntGen {
+	return &IntGen{source: fileName}
+}
+
+// newImports returns the import set shared by a domain file and its
+// interface file, with the layer aliases kept free for chainLayers.
+func newImports(layers []*Layer) *importSet {
+	reserved := make([]string, 0, len(layers))
+	for _, l := range layers {
+		reserved = append(reserved, l.Alias())
+	}
+	return newImportSet(reserved...)
+}
+
+func setImports(domainFile *DomainGenerator, intFile *IntGen, imports *importSet) {
+	domainFile.Imports = imports.list()
+	intFile.Imports = imports.list()
+}
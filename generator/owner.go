@@ -0,0 +1,105 @@
+package generator
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dotdak/go-templater/pkg/model"
+	"github.com/dotdak/go-templater/version"
+)
+
+var ErrNotOwned = errors.New("file has no gotem header, force to replace it")
+
+const (
+	generatedMarker = "// Code generated by gotem "
+	scaffoldMarker  = "// Scaffolded by gotem "
+	// legacyMarker heads the files of the versions before the markers.
+	legacyMarker = "// Generated code by gotem"
+)
+
+// origin is the input a file was generated from.
+type origin struct {
+	path string
+	hash string
+}
+
+func newOrigin(f *model.File) origin {
+	// marshalling a File can't fail, its fields are plain values
+	b, _ := json.Marshal(f)
+	sum := sha256.Sum256(b)
+	return origin{path: f.Path, hash: hex.EncodeToString(sum[:6])}
+}
+
+// generated is the header of the files gotem owns, regenerated as a whole.
+func (o origin) generated() string {
+	return fmt.Sprintf("%s%s from %s (input %s). DO NOT EDIT.", generatedMarker, version.Version, o.path, o.hash)
+}
+
+// scaffolded is the header of the stubs gotem writes once for the user to
+// fill in, only merged into afterwards.
+func (o origin) scaffolded() string {
+	return fmt.Sprintf("%s%s from %s (input %s), safe to edit.", scaffoldMarker, version.Version, o.path, o.hash)
+}
+
+// isMarker reports whether line is a gotem header.
+func isMarker(line []byte) bool {
+	line = bytes.TrimSpace(line)
+	return bytes.HasPrefix(line, []byte(generatedMarker)) ||
+		bytes.HasPrefix(line, []byte(scaffoldMarker)) ||
+		bytes.Equal(line, []byte(legacyMarker))
+}
+
+// marker is the gotem header src carries before its package clause, empty
+// when none.
+func marker(src []byte) string {
+	for _, line := range bytes.Split(src, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("package ")) {
+			return ""
+		}
+		if isMarker(line) {
+			return string(bytes.TrimSpace(line))
+		}
+	}
+	return ""
+}
+
+// owned reports whether src carries a gotem header.
+func owned(src []byte) bool {
+	return marker(src) != ""
+}
+
+// scaffold reports whether existing is a scaffold, which is merged into
+// rather than replaced: it is headed as one, or with the legacy header
+// while src, what would replace it, is one.
+func scaffold(existing, src []byte) bool {
+	m := marker(existing)
+	return strings.HasPrefix(m, scaffoldMarker) ||
+		m == legacyMarker && strings.HasPrefix(marker(src), scaffoldMarker)
+}
+
+// withHeader heads src with header, replacing the gotem header src starts
+// with, if any.
+func withHeader(src []byte, header string) []byte {
+	if header == "" {
+		return src
+	}
+	line, rest, _ := bytes.Cut(src, []byte("\n"))
+	if isMarker(line) {
+		return append([]byte(header+"\n"), rest...)
+	}
+	return append([]byte(header+"\n\n"), src...)
+}
+
+// headerOf is the gotem header src starts with, empty when none.
+func headerOf(src []byte) string {
+	line, _, _ := bytes.Cut(src, []byte("\n"))
+	if !isMarker(line) {
+		return ""
+	}
+	return string(line)
+}
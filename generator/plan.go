@@ -0,0 +1,133 @@
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/dotdak/go-templater/pkg/diff"
+	"github.com/dotdak/go-templater/pkg/manifest"
+)
+
+// file is a generated file, rendered from its template.
+type file interface {
+	name() string
+	// template is the name of the template the file is rendered with and
+	// its embedded default.
+	template() (name, embedded string)
+	from() origin
+	render(r *run) ([]byte, error)
+}
+
+// Change is the content a generated file has before and after the run,
+// Before is nil when the file doesn't exist yet.
+type Change struct {
+	Name   string
+	Before []byte
+	After  []byte
+
+	// entry records After in the manifest, nil when gotem didn't write it.
+	entry *manifest.File
+}
+
+// plan renders f and returns the changes writing it makes under the
+// Overwrite, Merge and Stale options, without writing to the sink. Files
+// without a gotem header are only replaced or merged into under Force,
+// Overwrite merges into scaffolds unless forced, and the files the
+// manifest shows are up to date aren't rendered again.
+func (r *run) plan(f file) ([]Change, error) {
+	fileName := f.name()
+	existing, err := r.opts.Sink.ReadFile(fileName)
+	exists := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	entry, err := r.entry(f)
+	if err != nil {
+		return nil, err
+	}
+	if old := r.recorded(entry, existing, exists); old != nil {
+		return []Change{{Name: fileName, Before: existing, After: existing, entry: old}}, nil
+	}
+
+	src, err := f.render(r)
+	if err != nil {
+		return nil, fmt.Errorf("render: %w", err)
+	}
+	if !exists {
+		return []Change{{Name: fileName, After: src, entry: written(entry, src, false)}}, nil
+	}
+
+	if (r.opts.Merge || r.opts.Overwrite) && !r.opts.Force && !owned(existing) {
+		return nil, ErrNotOwned
+	}
+
+	switch {
+	case r.opts.Merge, r.opts.Overwrite && !r.opts.Force && scaffold(existing, src):
+		merged, report, err := mergeSource(fileName, existing, src, r.opts.Stale)
+		if err != nil {
+			return nil, fmt.Errorf("merge %s: %w", fileName, err)
+		}
+		merged = withHeader(merged, headerOf(src))
+		for _, name := range report.Changed {
+			r.log.Printf("%s: signature of %s changed, update it by hand", fileName, name)
+		}
+		r.logStale(fileName, report)
+		changes := []Change{{Name: fileName, Before: existing, After: merged, entry: written(entry, merged, !bytes.Equal(merged, src))}}
+		if len(report.Removed) == 0 {
+			return changes, nil
+		}
+		before, err := r.opts.Sink.ReadFile(removedFileName(fileName))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		removed, err := removedSource(fileName, existing, before, report.Removed)
+		if err != nil {
+			return nil, err
+		}
+		return append(changes, Change{Name: removedFileName(fileName), Before: before, After: removed}), nil
+	case r.opts.Overwrite:
+		return []Change{{Name: fileName, Before: existing, After: src, entry: written(entry, src, false)}}, nil
+	default:
+		r.log.Printf("ignore %s, file exists", fileName)
+		r.reportStale(fileName, existing, src)
+		return []Change{{Name: fileName, Before: existing, After: existing}}, nil
+	}
+}
+
+// Status is "created", "updated" or "unchanged".
+func (c Change) Status() string {
+	switch {
+	case c.Before == nil:
+		return "created"
+	case bytes.Equal(c.Before, c.After):
+		return "unchanged"
+	default:
+		return "updated"
+	}
+}
+
+// Diff is the unified diff of the change, empty when unchanged.
+func (c Change) Diff() string {
+	name := c.RelName()
+	if c.Before == nil {
+		return diff.Unified("/dev/null", "b/"+name, nil, c.After)
+	}
+	return diff.Unified("a/"+name, "b/"+name, c.Before, c.After)
+}
+
+// RelName is Name relative to the working directory, when below it.
+func (c Change) RelName() string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return c.Name
+	}
+	rel, err := filepath.Rel(wd, c.Name)
+	if err != nil || filepath.IsAbs(rel) {
+		return c.Name
+	}
+	return filepath.ToSlash(rel)
+}
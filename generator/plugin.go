@@ -0,0 +1,85 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dotdak/go-templater/pkg/model"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// Plugin reads the services of the files protoc asked a plugin for.
+func Plugin(gen *protogen.Plugin) Source {
+	return SourceFunc(func(context.Context, Env) ([]*model.File, error) {
+		return readPlugin(gen), nil
+	})
+}
+
+// readPlugin reads the services of the files protoc asked for, the same
+// way readProto does from a parsed .proto file.
+func readPlugin(gen *protogen.Plugin) []*model.File {
+	var files []*model.File
+	for _, pf := range gen.Files {
+		if !pf.Generate || len(pf.Services) == 0 {
+			continue
+		}
+
+		imports := newImportSet()
+		f := &model.File{
+			Path:      pf.Desc.Path(),
+			GoPackage: string(pf.GoImportPath),
+			GoName:    imports.add(string(pf.GoImportPath), string(pf.GoPackageName)),
+		}
+		for _, service := range pf.Services {
+			s := &model.Service{
+				Name:    strings.TrimSuffix(service.GoName, "Service"),
+				Server:  service.GoName + "Server",
+				Comment: pluginComment(service.Comments.Leading),
+			}
+
+			for _, method := range service.Methods {
+				s.Methods = append(s.Methods, rpcMethod(
+					method.GoName,
+					pluginComment(method.Comments.Leading),
+					pluginType(gen, method.Input, imports),
+					pluginType(gen, method.Output, imports),
+					streamKind(method.Desc.IsStreamingClient(), method.Desc.IsStreamingServer()),
+					fmt.Sprintf("%s.%s_%sServer", f.GoName, service.GoName, method.GoName),
+					imports,
+				))
+			}
+
+			f.Services = append(f.Services, s)
+		}
+
+		f.Imports = imports.modelImports()
+		files = append(files, f)
+	}
+
+	return files
+}
+
+// pluginType is the Go pointer type of message, qualified with the package
+// name protoc-gen-go gives its file.
+func pluginType(gen *protogen.Plugin, message *protogen.Message, imports *importSet) string {
+	importPath := string(message.GoIdent.GoImportPath)
+	name := assumedName(importPath)
+	if f, ok := gen.FilesByPath[message.Desc.ParentFile().Path()]; ok {
+		name = string(f.GoPackageName)
+	}
+	return "*" + imports.add(importPath, name) + "." + message.GoIdent.GoName
+}
+
+func pluginComment(c protogen.Comments) string {
+	text := strings.TrimSpace(string(c))
+	if text == "" {
+		return ""
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = "// " + strings.TrimSpace(line)
+	}
+	return strings.Join(lines, "\n")
+}
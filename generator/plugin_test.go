@@ -0,0 +1,26 @@
+package generator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dotdak/go-templater/pkg/model"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func TestReadPlugin(t *testing.T) {
+	gen, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{"api/foo/v1/foo.proto"},
+		ProtoFile:      testProtos(t),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := readPlugin(gen)
+	if len(files) != 1 || !reflect.DeepEqual(files[0], fooFile) {
+		t.Errorf("read\n%s\nwant\n%s", dump(t, files), dump(t, []*model.File{fooFile}))
+	}
+}
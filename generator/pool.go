@@ -0,0 +1,60 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"sync"
+)
+
+// forEach calls fn with 0 to n-1 from workers goroutines at most. Once ctx
+// is done it stops handing out indexes and returns the context's error,
+// after the calls in flight return.
+func forEach(ctx context.Context, workers, n int, fn func(i int)) error {
+	if workers > n {
+		workers = n
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+
+	var err error
+	for i := 0; i < n && err == nil; i++ {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	close(next)
+	wg.Wait()
+	return err
+}
+
+// planned is the outcome of planning a file, along with what it logged.
+type planned struct {
+	changes []Change
+	err     error
+	log     []byte
+}
+
+// planLogged plans f holding its log back, for the files planned at once
+// to log in generation order.
+func (r *run) planLogged(f file) planned {
+	var buf bytes.Buffer
+	fr := *r
+	fr.log = log.New(&buf, r.log.Prefix(), r.log.Flags())
+	changes, err := fr.plan(f)
+	return planned{changes: changes, err: err, log: buf.Bytes()}
+}
@@ -0,0 +1,68 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestForEach(t *testing.T) {
+	const workers, n = 3, 50
+	var mu sync.Mutex
+	calls := make([]int, n)
+	var running, most int
+	err := forEach(context.Background(), workers, n, func(i int) {
+		mu.Lock()
+		calls[i]++
+		running++
+		if running > most {
+			most = running
+		}
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range calls {
+		if c != 1 {
+			t.Errorf("called %d %d times, want once", i, c)
+		}
+	}
+	if most > workers || most < 2 {
+		t.Errorf("%d calls at once, want 2 to %d", most, workers)
+	}
+}
+
+func TestForEachCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := forEach(ctx, 2, 10, func(i int) {
+		t.Errorf("called %d after ctx was done", i)
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("forEach = %v, want %v", err, context.Canceled)
+	}
+
+	// the call in flight returns, no other starts
+	ctx, cancel = context.WithCancel(context.Background())
+	var called []int
+	err = forEach(ctx, 1, 10, func(i int) {
+		called = append(called, i)
+		if i == 3 {
+			cancel()
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("forEach = %v, want %v", err, context.Canceled)
+	}
+	if len(called) != 4 {
+		t.Errorf("called %v, want 0 to 3", called)
+	}
+}
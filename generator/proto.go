@@ -0,0 +1,247 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dotdak/go-templater/pkg/model"
+	"github.com/dotdak/go-templater/pkg/shorten"
+
+	"github.com/yoheimuta/go-protoparser/v4"
+	proto_parser "github.com/yoheimuta/go-protoparser/v4/parser"
+)
+
+// wellKnownTypes maps protobuf well-known messages to their Go package and type.
+var wellKnownTypes = map[string]*Import{
+	"google.protobuf.Empty":     {Name: "emptypb", Path: "google.golang.org/protobuf/types/known/emptypb"},
+	"google.protobuf.Any":       {Name: "anypb", Path: "google.golang.org/protobuf/types/known/anypb"},
+	"google.protobuf.Timestamp": {Name: "timestamppb", Path: "google.golang.org/protobuf/types/known/timestamppb"},
+	"google.protobuf.Duration":  {Name: "durationpb", Path: "google.golang.org/protobuf/types/known/durationpb"},
+	"google.protobuf.Struct":    {Name: "structpb", Path: "google.golang.org/protobuf/types/known/structpb"},
+	"google.protobuf.FieldMask": {Name: "fieldmaskpb", Path: "google.golang.org/protobuf/types/known/fieldmaskpb"},
+}
+
+// protoFile holds what we need from a parsed .proto file to build generators.
+type protoFile struct {
+	Package   string
+	GoPackage string
+	GoName    string
+	Services  []*proto_parser.Service
+}
+
+func parseProtoFile(path string) (*protoFile, error) {
+	inputFile, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer inputFile.Close()
+
+	proto, err := protoparser.Parse(inputFile, protoparser.WithFilename(filepath.Base(path)))
+	if err != nil {
+		return nil, err
+	}
+
+	out := &protoFile{}
+	for _, body := range proto.ProtoBody {
+		switch x := body.(type) {
+		case *proto_parser.Package:
+			out.Package = x.Name
+		case *proto_parser.Option:
+			if x.OptionName != "go_package" {
+				continue
+			}
+			goPackage := strings.Trim(x.Constant, "\"")
+			parts := strings.SplitN(goPackage, ";", 2)
+			out.GoPackage = parts[0]
+			out.GoName = getPackageFromDir(parts[0])
+			if len(parts) == 2 {
+				out.GoName = parts[1]
+			}
+		case *proto_parser.Service:
+			out.Services = append(out.Services, x)
+		}
+	}
+
+	if out.GoPackage == "" {
+		return nil, fmt.Errorf("%s: missing go_package option", path)
+	}
+
+	return out, nil
+}
+
+// goType converts a proto message reference to its Go type, registering any
+// extra import the type needs.
+func (p *protoFile) goType(messageType string, imports *importSet, warn *log.Logger) string {
+	name := strings.TrimPrefix(messageType, ".")
+	if imp, ok := wellKnownTypes[name]; ok {
+		return fmt.Sprintf("*%s.%s", imports.add(imp.Path, imp.Name), name[strings.LastIndex(name, ".")+1:])
+	}
+
+	if p.Package != "" && strings.HasPrefix(name, p.Package+".") {
+		name = strings.TrimPrefix(name, p.Package+".")
+	} else if strings.Contains(name, ".") && !isNestedMessage(name) {
+		warn.Printf("cannot resolve %s, assuming it lives in %s", messageType, p.GoPackage)
+		name = name[strings.LastIndex(name, ".")+1:]
+	}
+
+	// nested messages are flattened with underscores by protoc-gen-go
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = goCamelCase(part)
+	}
+	return fmt.Sprintf("*%s.%s", imports.add(p.GoPackage, p.GoName), strings.Join(parts, "_"))
+}
+
+// goCamelCase is the Go name protoc-gen-go gives a proto identifier:
+// get_foo becomes GetFoo, a leading underscore an X.
+func goCamelCase(s string) string {
+	isLower := func(c byte) bool { return 'a' <= c && c <= 'z' }
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '.' && i+1 < len(s) && isLower(s[i+1]):
+		case c == '.':
+			b = append(b, '_')
+		case c == '_' && (i == 0 || s[i-1] == '.'):
+			b = append(b, 'X')
+		case c == '_' && i+1 < len(s) && isLower(s[i+1]):
+		case '0' <= c && c <= '9':
+			b = append(b, c)
+		default:
+			// a word starts upper case, the lower case letters after it
+			// are kept
+			if isLower(c) {
+				c -= 'a' - 'A'
+			}
+			b = append(b, c)
+			for ; i+1 < len(s) && isLower(s[i+1]); i++ {
+				b = append(b, s[i+1])
+			}
+		}
+	}
+	return string(b)
+}
+
+// isNestedMessage reports whether name looks like Outer.Inner rather than a
+// reference into another proto package, which are lower case by convention.
+func isNestedMessage(name string) bool {
+	for _, part := range strings.Split(name, ".") {
+		if part == "" || strings.ToLower(part[:1]) == part[:1] {
+			return false
+		}
+	}
+	return true
+}
+
+func protoComment(comments []*proto_parser.Comment) string {
+	lines := make([]string, 0, len(comments))
+	for _, c := range comments {
+		for _, line := range c.Lines() {
+			lines = append(lines, "// "+strings.TrimSpace(line))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Proto reads the services of a .proto file. Its imports aren't read,
+// messages of other packages are assumed to live in the go_package of the
+// file, with a warning.
+func Proto(path string) Source {
+	return pathSource{path: path, SourceFunc: func(ctx context.Context, env Env) ([]*model.File, error) {
+		f, err := readProto(path, env.Log)
+		if err != nil {
+			return nil, err
+		}
+		return []*model.File{f}, nil
+	}}
+}
+
+func readProto(in string, warn *log.Logger) (*model.File, error) {
+	absPath, err := filepath.Abs(in)
+	if err != nil {
+		return nil, err
+	}
+
+	proto, err := parseProtoFile(absPath)
+	if err != nil {
+		return nil, err
+	}
+
+	imports := newImportSet()
+	f := &model.File{
+		Path:      filepath.ToSlash(filepath.Clean(in)),
+		GoPackage: proto.GoPackage,
+		GoName:    imports.add(proto.GoPackage, proto.GoName),
+	}
+	for _, service := range proto.Services {
+		serviceName := goCamelCase(service.ServiceName)
+		s := &model.Service{
+			Name:    strings.TrimSuffix(serviceName, "Service"),
+			Server:  serviceName + "Server",
+			Comment: protoComment(service.Comments),
+		}
+
+		for _, serviceBody := range service.ServiceBody {
+			rpc, ok := serviceBody.(*proto_parser.RPC)
+			if !ok {
+				continue
+			}
+
+			rpcName := goCamelCase(rpc.RPCName)
+			s.Methods = append(s.Methods, rpcMethod(
+				rpcName,
+				protoComment(rpc.Comments),
+				proto.goType(rpc.RPCRequest.MessageType, imports, warn),
+				proto.goType(rpc.RPCResponse.MessageType, imports, warn),
+				streamKind(rpc.RPCRequest.IsStream, rpc.RPCResponse.IsStream),
+				fmt.Sprintf("%s.%s_%sServer", f.GoName, serviceName, rpcName),
+				imports,
+			))
+		}
+
+		f.Services = append(f.Services, s)
+	}
+
+	f.Imports = imports.modelImports()
+	return f, nil
+}
+
+// rpcMethod builds the method of an rpc taking reqType and returning
+// resType, with the signatures protoc-gen-go-grpc emits for each kind of
+// rpc.
+func rpcMethod(name, comment, reqType, resType string, stream StreamKind, streamType string, imports *importSet) *model.Method {
+	method := &model.Method{
+		Name:     name,
+		Comment:  comment,
+		Stream:   stream,
+		Request:  strings.TrimPrefix(reqType, "*"),
+		Response: strings.TrimPrefix(resType, "*"),
+	}
+	ctxType := imports.add("context", "context") + ".Context"
+
+	switch stream {
+	case ClientStream, BidiStream:
+		method.Args = []*model.Arg{{Name: "stream", Type: streamType}}
+		method.Returns = []*model.Arg{{Type: "error"}}
+	case ServerStream:
+		method.RequestArg = shorten.Lookup(reqType[strings.LastIndex(reqType, ".")+1:])
+		method.Args = []*model.Arg{
+			{Name: method.RequestArg, Type: reqType},
+			{Name: "stream", Type: streamType},
+		}
+		method.Returns = []*model.Arg{{Type: "error"}}
+	default:
+		method.RequestArg = shorten.Lookup(reqType[strings.LastIndex(reqType, ".")+1:])
+		method.Args = []*model.Arg{
+			{Name: shorten.Lookup("context"), Type: ctxType},
+			{Name: method.RequestArg, Type: reqType},
+		}
+		method.Returns = []*model.Arg{{Type: resType}, {Type: "error"}}
+	}
+	return method
+}
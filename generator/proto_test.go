@@ -0,0 +1,173 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dotdak/go-templater/pkg/model"
+)
+
+func TestReadProto(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		service *model.Service
+		imports []*model.Import
+		warning string
+	}{
+		{
+			name: "well known types",
+			body: `import "google/protobuf/empty.proto";
+
+service FooService {
+  rpc Ping(.google.protobuf.Empty) returns (google.protobuf.Empty);
+}
+`,
+			service: &model.Service{
+				Name:   "Foo",
+				Server: "FooServiceServer",
+				Methods: []*model.Method{{
+					Name:       "Ping",
+					Stream:     Unary,
+					Request:    "emptypb.Empty",
+					Response:   "emptypb.Empty",
+					RequestArg: "empty",
+					Args: []*model.Arg{
+						{Name: "ctx", Type: "context.Context"},
+						{Name: "empty", Type: "*emptypb.Empty"},
+					},
+					Returns: []*model.Arg{{Type: "*emptypb.Empty"}, {Type: "error"}},
+				}},
+			},
+			imports: []*model.Import{
+				{Name: "context", Path: "context"},
+				{Name: "foov1", Path: "example.com/fx/api/foo/v1"},
+				{Name: "emptypb", Path: "google.golang.org/protobuf/types/known/emptypb"},
+			},
+		},
+		{
+			name: "snake case names",
+			body: `message get_request {}
+message Outer {
+  message inner_reply {}
+}
+
+service foo_service {
+  rpc get_foo(get_request) returns (stream .foo.v1.Outer.inner_reply);
+}
+`,
+			service: &model.Service{
+				Name:   "Foo",
+				Server: "FooServiceServer",
+				Methods: []*model.Method{{
+					Name:       "GetFoo",
+					Stream:     ServerStream,
+					Request:    "foov1.GetRequest",
+					Response:   "foov1.Outer_InnerReply",
+					RequestArg: "req",
+					Args: []*model.Arg{
+						{Name: "req", Type: "*foov1.GetRequest"},
+						{Name: "stream", Type: "foov1.FooService_GetFooServer"},
+					},
+					Returns: []*model.Arg{{Type: "error"}},
+				}},
+			},
+			imports: []*model.Import{
+				{Name: "context", Path: "context"},
+				{Name: "foov1", Path: "example.com/fx/api/foo/v1"},
+			},
+		},
+		{
+			name: "other package",
+			body: `service FooService {
+  rpc Put(bar.v1.Bar) returns (bar.v1.Bar);
+}
+`,
+			service: &model.Service{
+				Name:   "Foo",
+				Server: "FooServiceServer",
+				Methods: []*model.Method{{
+					Name:       "Put",
+					Stream:     Unary,
+					Request:    "foov1.Bar",
+					Response:   "foov1.Bar",
+					RequestArg: "bar",
+					Args: []*model.Arg{
+						{Name: "ctx", Type: "context.Context"},
+						{Name: "bar", Type: "*foov1.Bar"},
+					},
+					Returns: []*model.Arg{{Type: "*foov1.Bar"}, {Type: "error"}},
+				}},
+			},
+			imports: []*model.Import{
+				{Name: "context", Path: "context"},
+				{Name: "foov1", Path: "example.com/fx/api/foo/v1"},
+			},
+			warning: "cannot resolve bar.v1.Bar, assuming it lives in example.com/fx/api/foo/v1\n" +
+				"cannot resolve bar.v1.Bar, assuming it lives in example.com/fx/api/foo/v1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, fooProto(tt.body))
+			var warnings bytes.Buffer
+			f, err := readProto(filepath.Join(dir, "api/foo/v1/foo.proto"), log.New(&warnings, "", 0))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if f.GoPackage != "example.com/fx/api/foo/v1" || f.GoName != "foov1" {
+				t.Errorf("package = %s %s, want example.com/fx/api/foo/v1 foov1", f.GoPackage, f.GoName)
+			}
+			if len(f.Services) != 1 {
+				t.Fatalf("got %d services, want 1", len(f.Services))
+			}
+			if !reflect.DeepEqual(f.Services[0], tt.service) {
+				t.Errorf("service =\n%s\nwant\n%s", dump(t, f.Services[0]), dump(t, tt.service))
+			}
+			if !reflect.DeepEqual(f.Imports, tt.imports) {
+				t.Errorf("imports =\n%s\nwant\n%s", dump(t, f.Imports), dump(t, tt.imports))
+			}
+			if warnings.String() != tt.warning {
+				t.Errorf("warnings = %q, want %q", warnings.String(), tt.warning)
+			}
+		})
+	}
+}
+
+func TestGoCamelCase(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Foo", "Foo"},
+		{"get_foo", "GetFoo"},
+		{"getFoo", "GetFoo"},
+		{"_foo", "XFoo"},
+		{"foo_bar2", "FooBar2"},
+		{"foo_2bar", "Foo_2Bar"},
+		{"HTTPServer", "HTTPServer"},
+		{"foo.bar", "FooBar"},
+		{"foo.Bar", "Foo_Bar"},
+	}
+	for _, tt := range tests {
+		if got := goCamelCase(tt.in); got != tt.want {
+			t.Errorf("goCamelCase(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// dump encodes v as JSON to compare in failures.
+func dump(t *testing.T, v any) string {
+	t.Helper()
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
@@ -0,0 +1,167 @@
+package generator
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// chdir changes the working directory to dir for the rest of the test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// writeFiles writes files, relative paths to content, below dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// fixture writes files into a new module example.com/fx and makes it the
+// working directory, returning its path.
+func fixture(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"go.mod": "module example.com/fx\n\ngo 1.22\n"})
+	writeFiles(t, dir, files)
+	chdir(t, dir)
+	return dir
+}
+
+// render generates from a fixture made of files, in memory. opts.Input
+// defaults to the proto file api/foo/v1/foo.proto.
+func render(t *testing.T, files map[string]string, opts Options) *Memory {
+	t.Helper()
+	fixture(t, files)
+
+	sink := NewMemory()
+	opts.Sink = sink
+	if opts.Input == nil {
+		opts.Input = Proto("api/foo/v1/foo.proto")
+	}
+	if _, err := Generate(context.Background(), opts); err != nil {
+		t.Fatal(err)
+	}
+	return sink
+}
+
+// fooProto is api/foo/v1/foo.proto declaring body.
+func fooProto(body string) map[string]string {
+	return map[string]string{"api/foo/v1/foo.proto": `syntax = "proto3";
+
+package foo.v1;
+
+option go_package = "example.com/fx/api/foo/v1;foov1";
+
+` + body}
+}
+
+// readGo parses the file name of sink.
+func readGo(t *testing.T, sink *Memory, name string) *ast.File {
+	t.Helper()
+	src, err := sink.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), name, src, 0)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, src)
+	}
+	return f
+}
+
+func TestRenderArgNames(t *testing.T) {
+	sink := render(t, fooProto(`message Status {}
+message Codes {}
+message Error {}
+message Reader {}
+
+service FooService {
+  rpc Set(Status) returns (Status);
+  rpc Check(Codes) returns (Codes);
+  rpc Fail(Error) returns (Error);
+  rpc Watch(Status) returns (stream Status);
+  rpc Read(Reader) returns (Reader);
+}
+`), Options{})
+
+	names := sink.Names()
+	if len(names) == 0 {
+		t.Fatal("nothing generated")
+	}
+	for _, name := range names {
+		if !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		f := readGo(t, sink, name)
+		taken := map[string]bool{"errors": true, "io": true, "status": true, "codes": true}
+		for _, imp := range f.Imports {
+			p, _ := strconv.Unquote(imp.Path.Value)
+			if imp.Name != nil {
+				taken[imp.Name.Name] = true
+			} else {
+				taken[path.Base(p)] = true
+			}
+		}
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			for _, field := range fn.Type.Params.List {
+				for _, id := range field.Names {
+					if taken[id.Name] {
+						t.Errorf("%s: %s: argument %s shadows a package", name, fn.Name.Name, id.Name)
+					}
+				}
+			}
+		}
+	}
+
+	handler, err := sink.ReadFile(mustAbs(t, "handlers/v1/foo_handler.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"ctx context.Context, status2 *foov1.Status,\n",
+		"status2 *foov1.Status, stream foov1.FooService_WatchServer,\n",
+		"h.fooService.Check(ctx, codes2)",
+		"h.fooService.Fail(ctx, err2)",
+	} {
+		if !strings.Contains(string(handler), want) {
+			t.Errorf("handler lacks %q:\n%s", want, handler)
+		}
+	}
+}
+
+func mustAbs(t *testing.T, name string) string {
+	t.Helper()
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return abs
+}
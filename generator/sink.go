@@ -0,0 +1,288 @@
+package generator
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/txtar"
+)
+
+var ErrArchiveFormat = errors.New("archive must end with .zip, .tar.gz, .tgz or .txtar")
+
+// Sink is the file system the generated files are written to, and the
+// files they replace or merge into are read from. Names are absolute
+// paths. Implementations must be safe for concurrent use: a run calls
+// them from up to Options.Parallel goroutines at once.
+type Sink interface {
+	// ReadFile returns the content of name, or an error wrapping
+	// fs.ErrNotExist when there is none.
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte) error
+	// Remove deletes name, to roll back the files a failed run created.
+	Remove(name string) error
+}
+
+// Disk is the Sink of the local disk, creating directories as needed and
+// removing those left empty.
+type Disk struct{}
+
+func (Disk) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+// WriteFile writes data to a temporary file next to name and renames it
+// into place, so name is never left half written. New files get 0644,
+// replaced ones keep their mode.
+func (Disk) WriteFile(name string, data []byte) error {
+	dir := filepath.Dir(name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	perm := fs.FileMode(0o644)
+	if fi, err := os.Stat(name); err == nil {
+		perm = fi.Mode().Perm()
+	}
+
+	f, err := os.CreateTemp(dir, "."+filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	// the rename leaves nothing to remove unless something failed
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
+// Remove deletes name and the directories it leaves empty, so a rolled back
+// run leaves none of the directories WriteFile created behind. Directories
+// that were empty before go too.
+func (Disk) Remove(name string) error {
+	if err := os.Remove(name); err != nil {
+		return err
+	}
+	// os.Remove refuses the first directory that isn't empty
+	for dir := filepath.Dir(name); os.Remove(dir) == nil; dir = filepath.Dir(dir) {
+	}
+	return nil
+}
+
+// Memory keeps the files in memory, to render in tests or previews. Seed
+// it with WriteFile to generate on top of existing files.
+type Memory struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func NewMemory() *Memory {
+	return &Memory{files: make(map[string][]byte)}
+}
+
+func (m *Memory) ReadFile(name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return append([]byte(nil), data...), nil
+}
+
+func (m *Memory) WriteFile(name string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[name] = append([]byte(nil), data...)
+	return nil
+}
+
+func (m *Memory) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.files[name]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	delete(m.files, name)
+	return nil
+}
+
+// Names returns the names of the files, sorted.
+func (m *Memory) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.files))
+	for name := range m.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ArchiveFormat is how an Archive lays out its files.
+type ArchiveFormat string
+
+const (
+	Zip   ArchiveFormat = "zip"
+	TarGz ArchiveFormat = "tar.gz"
+	// Txtar is the plain text format of golang.org/x/tools/txtar, for
+	// printing the files.
+	Txtar ArchiveFormat = "txtar"
+)
+
+// ArchiveFormatOf picks the format from the extension of name.
+func ArchiveFormatOf(name string) (ArchiveFormat, error) {
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return Zip, nil
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return TarGz, nil
+	case strings.HasSuffix(name, ".txtar"):
+		return Txtar, nil
+	default:
+		return "", fmt.Errorf("%s: %w", name, ErrArchiveFormat)
+	}
+}
+
+// modTime stamps the files of an archive, so the same files always make the
+// same archive. Zip can't go before 1980.
+var modTime = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Archive collects the files in memory and writes them to w on Close,
+// named relative to root. It starts empty, so every file is created.
+type Archive struct {
+	Memory
+	w      io.Writer
+	root   string
+	format ArchiveFormat
+}
+
+func NewArchive(w io.Writer, root string, format ArchiveFormat) *Archive {
+	return &Archive{
+		Memory: Memory{files: make(map[string][]byte)},
+		w:      w,
+		root:   root,
+		format: format,
+	}
+}
+
+// WriteFile rejects the files outside root, which the archive can't hold.
+func (a *Archive) WriteFile(name string, data []byte) error {
+	if _, err := a.rel(name); err != nil {
+		return err
+	}
+	return a.Memory.WriteFile(name, data)
+}
+
+func (a *Archive) rel(name string) (string, error) {
+	rel, err := filepath.Rel(a.root, name)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s: outside the archive root %s", name, a.root)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// Close writes the archive, it doesn't close w.
+func (a *Archive) Close() error {
+	switch a.format {
+	case Zip:
+		return a.writeZip()
+	case TarGz:
+		return a.writeTarGz()
+	case Txtar:
+		return a.writeTxtar()
+	default:
+		return fmt.Errorf("%s: %w", a.format, ErrArchiveFormat)
+	}
+}
+
+// each calls fn with the files in name order.
+func (a *Archive) each(fn func(name string, data []byte) error) error {
+	for _, name := range a.Names() {
+		data, err := a.Memory.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		rel, err := a.rel(name)
+		if err != nil {
+			return err
+		}
+		if err := fn(rel, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *Archive) writeZip() error {
+	zw := zip.NewWriter(a.w)
+	err := a.each(func(name string, data []byte) error {
+		h := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime}
+		h.SetMode(0o644)
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func (a *Archive) writeTarGz() error {
+	gw := gzip.NewWriter(a.w)
+	tw := tar.NewWriter(gw)
+	err := a.each(func(name string, data []byte) error {
+		h := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: modTime, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(h); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+func (a *Archive) writeTxtar() error {
+	ar := &txtar.Archive{}
+	err := a.each(func(name string, data []byte) error {
+		ar.Files = append(ar.Files, txtar.File{Name: name, Data: data})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	_, err = a.w.Write(txtar.Format(ar))
+	return err
+}
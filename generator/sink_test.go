@@ -0,0 +1,251 @@
+package generator
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/txtar"
+)
+
+func TestDiskWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "a", "b", "foo.go")
+	if err := (Disk{}).WriteFile(name, []byte("one")); err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, name, "one", 0o644)
+
+	// a replaced file keeps its mode
+	if err := os.Chmod(name, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := (Disk{}).WriteFile(name, []byte("two")); err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, name, "two", 0o600)
+
+	entries, err := os.ReadDir(filepath.Dir(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("left %d files in %s, want foo.go alone", len(entries), filepath.Dir(name))
+	}
+}
+
+func checkFile(t *testing.T, name, content string, mode fs.FileMode) {
+	t.Helper()
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != content {
+		t.Errorf("%s = %q, want %q", name, b, content)
+	}
+	fi, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != mode {
+		t.Errorf("%s mode = %v, want %v", name, fi.Mode().Perm(), mode)
+	}
+}
+
+func TestDiskRemove(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a/keep.go": "keep", "a/b/c/foo.go": "foo"})
+
+	if err := (Disk{}).Remove(filepath.Join(dir, "a", "b", "c", "foo.go")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a", "b")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("a/b left behind, want the directories emptied removed")
+	}
+	checkFile(t, filepath.Join(dir, "a", "keep.go"), "keep", 0o644)
+
+	if err := (Disk{}).Remove(filepath.Join(dir, "nope.go")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Remove of a missing file = %v, want %v", err, fs.ErrNotExist)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	m := NewMemory()
+	if _, err := m.ReadFile("/x/a.go"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadFile of a missing file = %v, want %v", err, fs.ErrNotExist)
+	}
+
+	data := []byte("a")
+	if err := m.WriteFile("/x/b.go", []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.WriteFile("/x/a.go", data); err != nil {
+		t.Fatal(err)
+	}
+	// the files are copies of what was written and read
+	data[0] = 'z'
+	got, err := m.ReadFile("/x/a.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got[0] = 'y'
+	if got, _ := m.ReadFile("/x/a.go"); string(got) != "a" {
+		t.Errorf("ReadFile = %q, want %q", got, "a")
+	}
+	if want := []string{"/x/a.go", "/x/b.go"}; !reflect.DeepEqual(m.Names(), want) {
+		t.Errorf("Names = %v, want %v", m.Names(), want)
+	}
+
+	if err := m.Remove("/x/a.go"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Remove("/x/a.go"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Remove of a missing file = %v, want %v", err, fs.ErrNotExist)
+	}
+	if want := []string{"/x/b.go"}; !reflect.DeepEqual(m.Names(), want) {
+		t.Errorf("Names = %v, want %v", m.Names(), want)
+	}
+}
+
+func TestArchiveFormatOf(t *testing.T) {
+	for name, want := range map[string]ArchiveFormat{
+		"out.zip":    Zip,
+		"out.tar.gz": TarGz,
+		"out.tgz":    TarGz,
+		"out.txtar":  Txtar,
+	} {
+		if got, err := ArchiveFormatOf(name); err != nil || got != want {
+			t.Errorf("ArchiveFormatOf(%s) = %s, %v, want %s", name, got, err, want)
+		}
+	}
+	if _, err := ArchiveFormatOf("out.tar"); !errors.Is(err, ErrArchiveFormat) {
+		t.Errorf("ArchiveFormatOf(out.tar) = %v, want %v", err, ErrArchiveFormat)
+	}
+}
+
+// archiveFile is a file read back from an archive.
+type archiveFile struct {
+	name, data string
+	mode       fs.FileMode
+}
+
+func TestArchive(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "root")
+	want := []archiveFile{
+		{"a.go", "package a\n", 0o644},
+		{"b/b.go", "package b\n", 0o644},
+	}
+
+	tests := []struct {
+		format ArchiveFormat
+		read   func(t *testing.T, b []byte) []archiveFile
+	}{
+		{Zip, readZip},
+		{TarGz, readTarGz},
+		{Txtar, readTxtar},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			// the same files written in any order make the same archive
+			var archives [][]byte
+			for _, order := range [][]int{{0, 1}, {1, 0}} {
+				var buf bytes.Buffer
+				a := NewArchive(&buf, root, tt.format)
+				for _, i := range order {
+					name := filepath.Join(root, filepath.FromSlash(want[i].name))
+					if err := a.WriteFile(name, []byte(want[i].data)); err != nil {
+						t.Fatal(err)
+					}
+				}
+				for _, name := range []string{filepath.Dir(root), filepath.Join(root, "..", "x.go"), "/elsewhere/x.go"} {
+					if err := a.WriteFile(name, []byte("x")); err == nil {
+						t.Errorf("WriteFile(%s) outside the root succeeded", name)
+					}
+				}
+				if err := a.Close(); err != nil {
+					t.Fatal(err)
+				}
+				archives = append(archives, buf.Bytes())
+			}
+
+			if !bytes.Equal(archives[0], archives[1]) {
+				t.Errorf("archives of the same files differ")
+			}
+			if got := tt.read(t, archives[0]); !reflect.DeepEqual(got, want) {
+				t.Errorf("archive holds %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func readZip(t *testing.T, b []byte) []archiveFile {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []archiveFile
+	for _, f := range zr.File {
+		if !f.Modified.Equal(modTime) {
+			t.Errorf("%s modified %v, want %v", f.Name, f.Modified, modTime)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, archiveFile{f.Name, string(data), f.Mode().Perm()})
+	}
+	return files
+}
+
+func readTarGz(t *testing.T, b []byte) []archiveFile {
+	t.Helper()
+	gr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gr)
+	var files []archiveFile
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !h.ModTime.Equal(modTime) {
+			t.Errorf("%s modified %v, want %v", h.Name, h.ModTime, modTime)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, archiveFile{h.Name, string(data), fs.FileMode(h.Mode).Perm()})
+	}
+	return files
+}
+
+// readTxtar reads the files of a txtar archive, which has no modes: they
+// read as 0644.
+func readTxtar(t *testing.T, b []byte) []archiveFile {
+	t.Helper()
+	var files []archiveFile
+	for _, f := range txtar.Parse(b).Files {
+		files = append(files, archiveFile{f.Name, string(f.Data), 0o644})
+	}
+	return files
+}
@@ -0,0 +1,184 @@
+package generator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// What merging does with methods whose RPC was removed from the proto.
+const (
+	StaleReport    = "report"
+	StaleDeprecate = "deprecate"
+	StaleMove      = "move"
+)
+
+const deprecatedNote = "// Deprecated: removed from proto."
+
+// staleEdits finds the exported methods of existing that belong to a
+// generated type but are no longer generated, records them in report and
+// returns the edits marking or cutting them out as mode asks. Interface
+// methods can't live in another file, so move only deprecates them.
+func staleEdits(fset *token.FileSet, existing []byte, oldFile, newFile *ast.File, mode string, report *mergeReport) []edit {
+	var (
+		edits      []edit
+		receivers  = make(map[string]bool)
+		generated  = make(map[string]bool)
+		interfaces = make(map[string]map[string]bool)
+		offset     = func(pos token.Pos) int { return fset.File(pos).Offset(pos) }
+	)
+	for _, decl := range newFile.Decls {
+		generated[declKey(decl)] = true
+		switch x := decl.(type) {
+		case *ast.FuncDecl:
+			if x.Recv != nil && len(x.Recv.List) > 0 {
+				receivers[exprString(x.Recv.List[0].Type)] = true
+			}
+		case *ast.GenDecl:
+			if it := interfaceOf(x); it != nil {
+				methods := make(map[string]bool)
+				for _, m := range it.Methods.List {
+					if len(m.Names) > 0 {
+						methods[m.Names[0].Name] = true
+					}
+				}
+				interfaces[declKey(x)] = methods
+			}
+		}
+	}
+
+	deprecate := func(node ast.Node, doc *ast.CommentGroup, indent string) {
+		switch {
+		case doc != nil && strings.Contains(doc.Text(), "Deprecated:"):
+		case doc != nil:
+			edits = append(edits, edit{offset: offset(doc.End()), text: "\n" + indent + "//\n" + indent + deprecatedNote})
+		default:
+			edits = append(edits, edit{offset: offset(node.Pos()), text: deprecatedNote + "\n" + indent})
+		}
+	}
+
+	for _, decl := range oldFile.Decls {
+		switch x := decl.(type) {
+		case *ast.FuncDecl:
+			if x.Recv == nil || len(x.Recv.List) == 0 || !x.Name.IsExported() {
+				continue
+			}
+			key := declKey(x)
+			if !receivers[exprString(x.Recv.List[0].Type)] || generated[key] {
+				continue
+			}
+			switch mode {
+			case StaleDeprecate:
+				deprecate(x, x.Doc, "")
+				report.Deprecated = append(report.Deprecated, key)
+			case StaleMove:
+				start, end := offset(x.Pos()), offset(x.End())
+				if x.Doc != nil {
+					start = offset(x.Doc.Pos())
+				}
+				report.Removed = append(report.Removed, string(existing[start:end]))
+				report.Moved = append(report.Moved, key)
+				// take the blank line before and the newline after along
+				if end < len(existing) && existing[end] == '\n' {
+					end++
+				}
+				if start >= 2 && existing[start-1] == '\n' && existing[start-2] == '\n' {
+					start--
+				}
+				edits = append(edits, edit{offset: start, end: end})
+			default:
+				report.Stale = append(report.Stale, key)
+			}
+		case *ast.GenDecl:
+			methods, ok := interfaces[declKey(x)]
+			it := interfaceOf(x)
+			if !ok || it == nil {
+				continue
+			}
+			for _, m := range it.Methods.List {
+				if len(m.Names) == 0 || !m.Names[0].IsExported() || methods[m.Names[0].Name] {
+					continue
+				}
+				key := x.Specs[0].(*ast.TypeSpec).Name.Name + "." + m.Names[0].Name
+				if mode == StaleReport {
+					report.Stale = append(report.Stale, key)
+					continue
+				}
+				deprecate(m, m.Doc, "\t")
+				report.Deprecated = append(report.Deprecated, key)
+			}
+		}
+	}
+
+	return edits
+}
+
+func (r *run) logStale(fileName string, report *mergeReport) {
+	for _, name := range report.Stale {
+		r.log.Printf("%s: %s has no RPC anymore, remove it or rerun with -stale", fileName, name)
+	}
+	for _, name := range report.Deprecated {
+		r.log.Printf("%s: %s has no RPC anymore, marked deprecated", fileName, name)
+	}
+	for _, name := range report.Moved {
+		r.log.Printf("%s: %s has no RPC anymore, moved to %s", fileName, name, removedFileName(fileName))
+	}
+}
+
+// reportStale lists the stale methods of fileName without touching it.
+func (r *run) reportStale(fileName string, existing, src []byte) {
+	_, report, err := mergeSource(fileName, existing, src, StaleReport)
+	if err != nil {
+		r.log.Printf("%s: %v", fileName, err)
+		return
+	}
+	r.logStale(fileName, report)
+}
+
+func removedFileName(fileName string) string {
+	return strings.TrimSuffix(fileName, ".go") + "_removed.go"
+}
+
+// removedSource returns the _removed.go file of fileName, whose current
+// content is removed, with decls appended along with the imports of
+// existing they use. Like merging, it leaves the code as written.
+func removedSource(fileName string, existing, removed []byte, decls []string) ([]byte, error) {
+	from, err := parser.ParseFile(token.NewFileSet(), fileName, existing, parser.ImportsOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	name := removedFileName(fileName)
+	if removed == nil {
+		// the blank line keeps the comment from documenting the package
+		removed = []byte("// Methods whose RPC was removed from the proto, moved here by gotem.\n\npackage " + from.Name.Name + "\n")
+	}
+	edits := make([]edit, 0, len(decls))
+	for _, decl := range decls {
+		edits = append(edits, edit{offset: len(removed), text: "\n" + decl + "\n"})
+	}
+
+	src, err := editImports(name, applyEdits(removed, edits), func(fset *token.FileSet, f *ast.File) {
+		have := make(map[string]bool, len(f.Imports))
+		for _, imp := range f.Imports {
+			have[importName(imp)] = true
+		}
+		used := qualifiers(f)
+		for _, imp := range from.Imports {
+			if name := importName(imp); used[name] && !have[name] {
+				path, _ := strconv.Unquote(imp.Path.Value)
+				astutil.AddNamedImport(fset, f, nameOf(imp), path)
+			}
+		}
+		ast.SortImports(fset, f)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return src, nil
+}
@@ -0,0 +1,124 @@
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"text/template"
+)
+
+// render executes the template called name with data, adding the imports
+// registered by the template to imports and formatting the result under
+// header.
+func (r *run) render(fileName, name, embedded string, imports []*Import, data any, header string) ([]byte, error) {
+	parsed, err := r.templates.parsed(name, embedded)
+	if err != nil {
+		return nil, err
+	}
+	// the clone binds import to the imports of this file
+	tmpl, err := parsed.Clone()
+	if err != nil {
+		return nil, err
+	}
+	set := renderImports(imports)
+	seeded := len(set.imports)
+	tmpl.Funcs(templateFuncs(set))
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return nil, err
+	}
+	src, err := addImports(b.Bytes(), set, seeded)
+	if err != nil {
+		return nil, err
+	}
+	if src, err = formatSource(fileName, src); err != nil {
+		return nil, err
+	}
+	return withHeader(src, header), nil
+}
+
+// templates parses each template of a run once, for the files rendered
+// with it to share.
+type templates struct {
+	dir   string
+	mu    sync.Mutex
+	cache map[templateKey]*cachedTemplate
+}
+
+// templateKey tells the templates apart by name and by the default they
+// fall back to.
+type templateKey struct {
+	name, embedded string
+}
+
+type cachedTemplate struct {
+	text string
+	tmpl *template.Template
+	err  error
+}
+
+func newTemplates(dir string) *templates {
+	return &templates{dir: dir, cache: make(map[templateKey]*cachedTemplate)}
+}
+
+// load reads and parses the template called name from dir, falling back
+// to the embedded default when the directory doesn't override it.
+func (t *templates) load(name, embedded string) (*cachedTemplate, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := templateKey{name, embedded}
+	if c, ok := t.cache[key]; ok {
+		return c, nil
+	}
+
+	text, err := templateText(t.dir, name, embedded)
+	if err != nil {
+		return nil, err
+	}
+	c := &cachedTemplate{text: text}
+	// import is bound to the imports of each file when rendering it
+	c.tmpl, c.err = template.New(name).Funcs(templateFuncs(newImportSet())).Parse(text)
+	t.cache[key] = c
+	return c, nil
+}
+
+// text is the text of the template called name.
+func (t *templates) text(name, embedded string) (string, error) {
+	c, err := t.load(name, embedded)
+	if err != nil {
+		return "", err
+	}
+	return c.text, nil
+}
+
+// parsed is the template called name, to Clone before binding its
+// functions.
+func (t *templates) parsed(name, embedded string) (*template.Template, error) {
+	c, err := t.load(name, embedded)
+	if err != nil {
+		return nil, err
+	}
+	return c.tmpl, c.err
+}
+
+func templateText(dir, name, embedded string) (string, error) {
+	if dir == "" {
+		return embedded, nil
+	}
+
+	for _, fileName := range []string{name, name + ".tmpl"} {
+		b, err := os.ReadFile(filepath.Join(dir, fileName))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+
+	return embedded, nil
+}
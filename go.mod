@@ -1,14 +1,14 @@
 module github.com/dotdak/go-templater
 
-go 1.19
+go 1.25.0
 
 require (
 	github.com/peterbourgon/ff/v3 v3.3.0
 	github.com/yoheimuta/go-protoparser/v4 v4.6.0
-	golang.org/x/tools v0.2.0
+	golang.org/x/mod v0.37.0
+	golang.org/x/tools v0.47.0
+	google.golang.org/protobuf v1.36.5
+	gopkg.in/yaml.v2 v2.4.0
 )
 
-require (
-	golang.org/x/mod v0.6.0 // indirect
-	golang.org/x/sys v0.1.0 // indirect
-)
+require golang.org/x/sync v0.21.0 // indirect
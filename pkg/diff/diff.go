@@ -0,0 +1,159 @@
+// Package diff renders line based unified diffs.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Context is the number of unchanged lines shown around each change.
+const Context = 3
+
+type op struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// Unified returns the unified diff turning a into b, labelled with the
+// names aName and bName, or "" when they are equal.
+func Unified(aName, bName string, a, b []byte) string {
+	if string(a) == string(b) {
+		return ""
+	}
+
+	ops := lineOps(lines(string(a)), lines(string(b)))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+	for start := 0; start < len(ops); {
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+
+		// extend the hunk while the context between changes doesn't outgrow
+		// what two hunks would show
+		from, to := max(start-Context, 0), start
+		for i := start; i < len(ops) && i-to <= 2*Context+1; i++ {
+			if ops[i].kind != ' ' {
+				to = i
+			}
+		}
+		end := min(to+Context+1, len(ops))
+		writeHunk(&out, ops, from, end)
+		start = end
+	}
+	return out.String()
+}
+
+func writeHunk(out *strings.Builder, ops []op, from, end int) {
+	aLine, bLine := 1, 1
+	for _, o := range ops[:from] {
+		if o.kind != '+' {
+			aLine++
+		}
+		if o.kind != '-' {
+			bLine++
+		}
+	}
+	var aLen, bLen int
+	for _, o := range ops[from:end] {
+		if o.kind != '+' {
+			aLen++
+		}
+		if o.kind != '-' {
+			bLen++
+		}
+	}
+	// an empty range names the line before it
+	if aLen == 0 {
+		aLine--
+	}
+	if bLen == 0 {
+		bLine--
+	}
+
+	fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", aLine, aLen, bLine, bLen)
+	for _, o := range ops[from:end] {
+		out.WriteByte(o.kind)
+		out.WriteString(o.line)
+		if !strings.HasSuffix(o.line, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// lines splits s after each newline, the last line may lack one.
+func lines(s string) []string {
+	l := strings.SplitAfter(s, "\n")
+	if l[len(l)-1] == "" {
+		l = l[:len(l)-1]
+	}
+	return l
+}
+
+// lineOps turns a into b through the longest common subsequence of lines.
+// Only the lines between the common prefix and suffix, usually a few, take
+// the quadratic table.
+func lineOps(a, b []string) []op {
+	var pre, suf int
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+
+	ops := make([]op, 0, max(len(a), len(b)))
+	for _, l := range a[:pre] {
+		ops = append(ops, op{' ', l})
+	}
+	ops = lcsOps(ops, a[pre:len(a)-suf], b[pre:len(b)-suf])
+	for _, l := range a[len(a)-suf:] {
+		ops = append(ops, op{' ', l})
+	}
+	return ops
+}
+
+// lcsOps appends the ops turning a into b to ops.
+func lcsOps(ops []op, a, b []string) []op {
+	// lcs[i][j] is the length of the LCS of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, op{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, op{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, op{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, op{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, op{'+', b[j]})
+	}
+	return ops
+}
@@ -0,0 +1,124 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numbered returns the lines 1 to n, each holding its number, with the
+// lines of edits replaced by their text, or dropped when empty.
+func numbered(n int, edits map[int]string) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		line, ok := edits[i]
+		if !ok {
+			line = fmt.Sprintf("%d\n", i)
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+func hunks(diff string) []string {
+	var headers []string
+	for _, line := range strings.Split(diff, "\n") {
+		if strings.HasPrefix(line, "@@") {
+			headers = append(headers, line)
+		}
+	}
+	return headers
+}
+
+func TestUnifiedHunks(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []string
+	}{
+		{
+			name: "created",
+			b:    "a\nb\n",
+			want: []string{"@@ -0,0 +1,2 @@"},
+		},
+		{
+			name: "emptied",
+			a:    "a\nb\n",
+			want: []string{"@@ -1,2 +0,0 @@"},
+		},
+		{
+			name: "changed in the middle",
+			a:    numbered(20, nil),
+			b:    numbered(20, map[int]string{10: "ten\n"}),
+			want: []string{"@@ -7,7 +7,7 @@"},
+		},
+		{
+			name: "inserted at the top",
+			a:    numbered(10, nil),
+			b:    "0\n" + numbered(10, nil),
+			want: []string{"@@ -1,3 +1,4 @@"},
+		},
+		{
+			name: "appended",
+			a:    numbered(10, nil),
+			b:    numbered(10, nil) + "11\n12\n",
+			want: []string{"@@ -8,3 +8,5 @@"},
+		},
+		{
+			name: "removed at the end",
+			a:    numbered(10, nil),
+			b:    numbered(8, nil),
+			want: []string{"@@ -6,5 +6,3 @@"},
+		},
+		{
+			name: "inserted in an empty range",
+			a:    numbered(3, nil),
+			b:    numbered(3, map[int]string{2: "2\nnew\n"}),
+			want: []string{"@@ -1,3 +1,4 @@"},
+		},
+		{
+			name: "changes sharing context",
+			a:    numbered(30, nil),
+			b:    numbered(30, map[int]string{5: "", 12: "twelve\n"}),
+			want: []string{"@@ -2,14 +2,13 @@"},
+		},
+		{
+			name: "distant changes",
+			a:    numbered(30, nil),
+			b:    numbered(30, map[int]string{5: "", 13: "thirteen\n"}),
+			want: []string{"@@ -2,7 +2,6 @@", "@@ -10,7 +9,7 @@"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var a []byte
+			if tt.a != "" {
+				a = []byte(tt.a)
+			}
+			got := hunks(Unified("a", "b", a, []byte(tt.b)))
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("hunks %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnified(t *testing.T) {
+	if got := Unified("a", "b", []byte("x\n"), []byte("x\n")); got != "" {
+		t.Errorf("equal files diff to %q", got)
+	}
+
+	got := Unified("a/f", "b/f", []byte("1\n2\n3"), []byte("1\n2\nthree\n"))
+	want := `--- a/f
++++ b/f
+@@ -1,3 +1,3 @@
+ 1
+ 2
+-3
+\ No newline at end of file
++three
+`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
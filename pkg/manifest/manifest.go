@@ -0,0 +1,115 @@
+// Package manifest is the lock file listing the files gotem generated,
+// .gotem.lock by default. It tells generated files apart from hand edits,
+// lets gotem clean remove the files of services that no longer exist and
+// lets gen skip the files whose input didn't change.
+package manifest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Version is the version of the manifest written by Encode. Decode rejects
+// any other.
+const Version = "v1"
+
+var ErrVersion = errors.New("unsupported manifest version")
+
+type Manifest struct {
+	Version string  `json:"version"`
+	Files   []*File `json:"files"`
+}
+
+// File is a file gotem wrote.
+type File struct {
+	// Path is slash separated, relative to the directory of the manifest.
+	Path string `json:"path"`
+	// Input is the file the services were read from and InputHash the hash
+	// of what was read.
+	Input     string `json:"input"`
+	InputHash string `json:"input_hash"`
+	// Source is what the run read Input from: a file or directory relative
+	// to the directory of the manifest, or a module@version. Empty when the
+	// source doesn't tell.
+	Source string `json:"source,omitempty"`
+	// Template hashes the template, the gotem version and the layers the
+	// file was rendered with.
+	Template string `json:"template"`
+	// Hash is the Sum of the content written.
+	Hash string `json:"hash"`
+	// Merged is set when the content was merged into an existing file
+	// rather than rendered as is.
+	Merged bool `json:"merged,omitempty"`
+}
+
+func New() *Manifest {
+	return &Manifest{Version: Version}
+}
+
+// Decode reads a manifest written by Encode.
+func Decode(data []byte) (*Manifest, error) {
+	m := &Manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	if m.Version != Version {
+		return nil, fmt.Errorf("%w %q, want %s", ErrVersion, m.Version, Version)
+	}
+	return m, nil
+}
+
+// Encode writes the manifest as indented JSON, its files sorted by path.
+func (m *Manifest) Encode() ([]byte, error) {
+	sort.Slice(m.Files, func(i, j int) bool {
+		return m.Files[i].Path < m.Files[j].Path
+	})
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Lookup returns the file at path, nil when not listed.
+func (m *Manifest) Lookup(path string) *File {
+	for _, f := range m.Files {
+		if f.Path == path {
+			return f
+		}
+	}
+	return nil
+}
+
+// Put adds f, replacing the file listed at the same path.
+func (m *Manifest) Put(f *File) {
+	for i, old := range m.Files {
+		if old.Path == f.Path {
+			m.Files[i] = f
+			return
+		}
+	}
+	m.Files = append(m.Files, f)
+}
+
+// Remove drops the file at path.
+func (m *Manifest) Remove(path string) {
+	for i, f := range m.Files {
+		if f.Path == path {
+			m.Files = append(m.Files[:i], m.Files[i+1:]...)
+			return
+		}
+	}
+}
+
+// Sum is the hash of content recorded in File.Hash.
+func Sum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,48 @@
+package manifest
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	m := New()
+	m.Put(&File{Path: "services/foo_service.go", Input: "foo.proto", Source: "foo.proto", Hash: Sum([]byte("a"))})
+	m.Put(&File{Path: "handlers/foo_handler.go", Input: "foo.proto", Merged: true})
+	b, err := m.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Errorf("decoded %+v, want %+v", got, m)
+	}
+	if got.Files[0].Path != "handlers/foo_handler.go" {
+		t.Errorf("files not sorted by path: %s first", got.Files[0].Path)
+	}
+
+	if _, err := Decode([]byte(`{"version": "v0", "files": []}`)); !errors.Is(err, ErrVersion) {
+		t.Errorf("decoding v0: %v, want %v", err, ErrVersion)
+	}
+}
+
+func TestPutRemove(t *testing.T) {
+	m := New()
+	m.Put(&File{Path: "a.go", Hash: "1"})
+	m.Put(&File{Path: "b.go", Hash: "2"})
+	m.Put(&File{Path: "a.go", Hash: "3"})
+	if len(m.Files) != 2 || m.Lookup("a.go").Hash != "3" {
+		t.Errorf("put replaced nothing: %+v", m.Files)
+	}
+
+	m.Remove("a.go")
+	m.Remove("c.go")
+	if m.Lookup("a.go") != nil || m.Lookup("b.go") == nil {
+		t.Errorf("remove a.go left %+v", m.Files)
+	}
+}
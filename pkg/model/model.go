@@ -0,0 +1,140 @@
+// Package model describes the gRPC services gotem reads from its inputs,
+// before they are laid out in layers and rendered. gotem inspect writes it
+// and gotem gen -model renders from it, so other tools can share what gotem
+// understands about services.
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Version is the version of the model written by Encode. Decode rejects
+// any other.
+const Version = "v1"
+
+var (
+	ErrVersion = errors.New("unsupported model version")
+	ErrFormat  = errors.New("format must be json or yaml")
+)
+
+// Model is everything read from the inputs of a run.
+type Model struct {
+	Version string  `json:"version" yaml:"version"`
+	Files   []*File `json:"files" yaml:"files"`
+}
+
+// File is an input declaring services, a .proto file or the _grpc.pb.go
+// file protoc-gen-go-grpc generated.
+type File struct {
+	// Path is the .proto file as imported by protoc, or the import path of
+	// the Go package followed by the file name.
+	Path string `json:"path" yaml:"path"`
+	// GoPackage is the import path of the gRPC stubs and GoName the name
+	// of their package.
+	GoPackage string `json:"go_package" yaml:"go_package"`
+	GoName    string `json:"go_name" yaml:"go_name"`
+	// Imports are the packages the types of the file are qualified with.
+	Imports  []*Import  `json:"imports" yaml:"imports"`
+	Services []*Service `json:"services" yaml:"services"`
+}
+
+// Import is a package along with the name qualifying its types.
+type Import struct {
+	Name string `json:"name" yaml:"name"`
+	Path string `json:"path" yaml:"path"`
+}
+
+type Service struct {
+	// Name drops the Service suffix, Foo for FooService.
+	Name string `json:"name" yaml:"name"`
+	// Server is the server interface of the stubs, such as FooServiceServer.
+	Server string `json:"server" yaml:"server"`
+	// Comment is the doc comment, // markers included.
+	Comment string    `json:"comment,omitempty" yaml:"comment,omitempty"`
+	Methods []*Method `json:"methods" yaml:"methods"`
+}
+
+// StreamKind tells which sides of an rpc stream messages.
+type StreamKind string
+
+const (
+	Unary        StreamKind = "unary"
+	ServerStream StreamKind = "server"
+	ClientStream StreamKind = "client"
+	BidiStream   StreamKind = "bidi"
+)
+
+type Method struct {
+	Name string `json:"name" yaml:"name"`
+	// Comment is the doc comment, // markers included.
+	Comment string     `json:"comment,omitempty" yaml:"comment,omitempty"`
+	Stream  StreamKind `json:"stream" yaml:"stream"`
+	// Request and Response are the qualified message types without the
+	// pointer.
+	Request  string `json:"request" yaml:"request"`
+	Response string `json:"response" yaml:"response"`
+	// RequestArg is the name of the request argument, empty when the
+	// requests arrive through the stream.
+	RequestArg string `json:"request_arg,omitempty" yaml:"request_arg,omitempty"`
+	// Args and Returns make up the signature of the server method.
+	Args    []*Arg `json:"args" yaml:"args"`
+	Returns []*Arg `json:"returns" yaml:"returns"`
+}
+
+// Arg is a parameter or a result, results have no name.
+type Arg struct {
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	Type string `json:"type" yaml:"type"`
+}
+
+// New returns an empty model of the current version.
+func New() *Model {
+	return &Model{Version: Version}
+}
+
+// Encode writes m to w as json or yaml.
+func (m *Model) Encode(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(m)
+	case "yaml":
+		b, err := yaml.Marshal(m)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	default:
+		return fmt.Errorf("%s: %w", format, ErrFormat)
+	}
+}
+
+// Decode reads a model written by Encode in either format.
+func Decode(r io.Reader) (*Model, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Model{}
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("{")) {
+		err = json.Unmarshal(b, m)
+	} else {
+		err = yaml.Unmarshal(b, m)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if m.Version != Version {
+		return nil, fmt.Errorf("%q: %w", m.Version, ErrVersion)
+	}
+	return m, nil
+}
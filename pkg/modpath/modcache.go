@@ -0,0 +1,93 @@
+package modpath
+
+import (
+	"errors"
+	"fmt"
+	"go/build"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/module"
+)
+
+var ErrNotCached = errors.New("not found in module cache, run go mod download first")
+
+// ModCache returns the module cache directory, asking the go command first so
+// GOMODCACHE and GOPATH set through go env are honoured too.
+func ModCache() string {
+	if out, err := exec.Command("go", "env", "GOMODCACHE").Output(); err == nil {
+		if dir := strings.TrimSpace(string(out)); dir != "" {
+			return dir
+		}
+	}
+	if dir := os.Getenv("GOMODCACHE"); dir != "" {
+		return dir
+	}
+
+	gopath := os.Getenv("GOPATH")
+	if gopath == "" {
+		gopath = build.Default.GOPATH
+	}
+	return filepath.Join(filepath.SplitList(gopath)[0], "pkg", "mod")
+}
+
+// CachedDir returns the module cache directory of the package pkgPath at the
+// given version of its module. The module path is the longest prefix of
+// pkgPath present in the cache.
+func (r *Resolver) CachedDir(pkgPath, version string) (string, error) {
+	encVersion, err := module.EscapeVersion(version)
+	if err != nil {
+		return "", err
+	}
+
+	for prefix := pkgPath; strings.Contains(prefix, "/"); prefix = path.Dir(prefix) {
+		if dir, ok := r.cachedModule(prefix, version, encVersion); ok {
+			rel := strings.TrimPrefix(strings.TrimPrefix(pkgPath, prefix), "/")
+			return filepath.Join(dir, filepath.FromSlash(rel)), nil
+		}
+	}
+
+	return "", fmt.Errorf("%s@%s: %w", pkgPath, version, ErrNotCached)
+}
+
+func (r *Resolver) cachedModule(modPath, version, encVersion string) (string, bool) {
+	if _, pathMajor, ok := module.SplitPathVersion(modPath); !ok || module.CheckPathMajor(version, pathMajor) != nil {
+		return "", false
+	}
+	encPath, err := module.EscapePath(modPath)
+	if err != nil {
+		return "", false
+	}
+
+	dir := filepath.Join(r.modCache, filepath.FromSlash(encPath)+"@"+encVersion)
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		return "", false
+	}
+	return dir, true
+}
+
+// cachedImportPath decodes the import path of dir inside the module cache.
+func (r *Resolver) cachedImportPath(dir string) (string, error) {
+	rel, err := filepath.Rel(r.modCache, dir)
+	if err != nil {
+		return "", err
+	}
+	rel = filepath.ToSlash(rel)
+
+	at := strings.Index(rel, "@")
+	if at < 0 {
+		return "", fmt.Errorf("%s: not a module directory", dir)
+	}
+	modPath, err := module.UnescapePath(rel[:at])
+	if err != nil {
+		return "", err
+	}
+
+	if slash := strings.Index(rel[at:], "/"); slash >= 0 {
+		return path.Join(modPath, rel[at+slash+1:]), nil
+	}
+	return modPath, nil
+}
@@ -0,0 +1,177 @@
+// Package modpath resolves directories on disk to Go import paths using the
+// enclosing go.mod, go.work, replace directives and vendor directories.
+package modpath
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+var ErrNoModule = errors.New("no go.mod found")
+
+// Resolver maps directories to import paths as seen from a main module.
+type Resolver struct {
+	// replaces maps local replacement directories to the module path they replace.
+	replaces map[string]string
+	modCache string
+}
+
+// NewResolver collects the local replace directives of the main module
+// enclosing wd and of the active go.work, if any.
+func NewResolver(wd string) (*Resolver, error) {
+	wd, err := filepath.Abs(wd)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Resolver{
+		replaces: make(map[string]string),
+		modCache: ModCache(),
+	}
+	if modFile, ok := findUp(wd, "go.mod"); ok {
+		data, err := os.ReadFile(modFile)
+		if err != nil {
+			return nil, err
+		}
+		f, err := modfile.Parse(modFile, data, nil)
+		if err != nil {
+			return nil, err
+		}
+		r.addReplaces(filepath.Dir(modFile), f.Replace)
+	}
+
+	if workFile := findWork(wd); workFile != "" {
+		data, err := os.ReadFile(workFile)
+		if err != nil {
+			return nil, err
+		}
+		f, err := modfile.ParseWork(workFile, data, nil)
+		if err != nil {
+			return nil, err
+		}
+		r.addReplaces(filepath.Dir(workFile), f.Replace)
+	}
+
+	return r, nil
+}
+
+func (r *Resolver) addReplaces(base string, replaces []*modfile.Replace) {
+	for _, rep := range replaces {
+		if !modfile.IsDirectoryPath(rep.New.Path) {
+			continue
+		}
+		dir := rep.New.Path
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(base, dir)
+		}
+		r.replaces[filepath.Clean(dir)] = rep.Old.Path
+	}
+}
+
+// ImportPath returns the import path of the package in dir.
+func (r *Resolver) ImportPath(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	importPath, err := r.importPath(dir)
+	if err != nil {
+		return "", err
+	}
+
+	if err := module.CheckImportPath(importPath); err != nil {
+		return "", fmt.Errorf("%s: %w", dir, err)
+	}
+
+	return importPath, nil
+}
+
+func (r *Resolver) importPath(dir string) (string, error) {
+	if within(r.modCache, dir) {
+		return r.cachedImportPath(dir)
+	}
+
+	// longest replacement directory wins, like nested modules do
+	var replaced, replacedDir string
+	for repDir, modPath := range r.replaces {
+		if within(repDir, dir) && len(repDir) > len(replacedDir) {
+			replaced, replacedDir = modPath, repDir
+		}
+	}
+	if replacedDir != "" {
+		return join(replaced, replacedDir, dir), nil
+	}
+
+	modFile, ok := findUp(dir, "go.mod")
+	if !ok {
+		return "", fmt.Errorf("%s: %w", dir, ErrNoModule)
+	}
+	root := filepath.Dir(modFile)
+
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return "", err
+	}
+	if rel = filepath.ToSlash(rel); strings.HasPrefix(rel, "vendor/") {
+		return strings.TrimPrefix(rel, "vendor/"), nil
+	}
+
+	data, err := os.ReadFile(modFile)
+	if err != nil {
+		return "", err
+	}
+	modPath := modfile.ModulePath(data)
+	if modPath == "" {
+		return "", fmt.Errorf("%s: missing module directive", modFile)
+	}
+
+	return join(modPath, root, dir), nil
+}
+
+func join(modPath, root, dir string) string {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == "." {
+		return modPath
+	}
+	return path.Join(modPath, filepath.ToSlash(rel))
+}
+
+func within(root, dir string) bool {
+	return dir == root || strings.HasPrefix(dir, root+string(filepath.Separator))
+}
+
+// findUp looks for name in dir and its parents.
+func findUp(dir, name string) (string, bool) {
+	for {
+		candidate := filepath.Join(dir, name)
+		if fi, err := os.Stat(candidate); err == nil && !fi.IsDir() {
+			return candidate, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// findWork honours GOWORK the way the go command does.
+func findWork(wd string) string {
+	switch gowork := os.Getenv("GOWORK"); gowork {
+	case "off":
+		return ""
+	case "":
+		workFile, _ := findUp(wd, "go.work")
+		return workFile
+	default:
+		return gowork
+	}
+}
@@ -0,0 +1,214 @@
+package modpath
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFiles writes files, relative paths to content, below dir. A name
+// ending in / is created as an empty directory.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if content == "" {
+			if err := os.MkdirAll(name, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestImportPath(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		// wd is where the resolver is made, dir what is resolved, both
+		// relative to the temp dir.
+		wd, dir string
+		want    string
+		err     error
+	}{
+		{
+			name:  "module root",
+			files: map[string]string{"m/go.mod": "module example.com/m\n"},
+			wd:    "m", dir: "m",
+			want: "example.com/m",
+		},
+		{
+			name:  "package",
+			files: map[string]string{"m/go.mod": "module example.com/m\n", "m/api/foo/v1/": ""},
+			wd:    "m", dir: "m/api/foo/v1",
+			want: "example.com/m/api/foo/v1",
+		},
+		{
+			name: "nested module",
+			files: map[string]string{
+				"m/go.mod":     "module example.com/m\n",
+				"m/api/go.mod": "module example.com/api\n",
+				"m/api/foo/":   "",
+			},
+			wd: "m", dir: "m/api/foo",
+			want: "example.com/api/foo",
+		},
+		{
+			name: "vendor",
+			files: map[string]string{
+				"m/go.mod":                    "module example.com/m\n",
+				"m/vendor/example.com/dep/x/": "",
+			},
+			wd: "m", dir: "m/vendor/example.com/dep/x",
+			want: "example.com/dep/x",
+		},
+		{
+			name: "go.mod replace",
+			files: map[string]string{
+				"m/go.mod":   "module example.com/m\n\nreplace example.com/dep => ../dep\n",
+				"dep/go.mod": "module example.com/other\n",
+				"dep/x/":     "",
+			},
+			wd: "m", dir: "dep/x",
+			want: "example.com/dep/x",
+		},
+		{
+			name: "longest replace",
+			files: map[string]string{
+				"m/go.mod": "module example.com/m\n\n" +
+					"replace example.com/dep => ../dep\n\n" +
+					"replace example.com/sub => ../dep/sub\n",
+				"dep/sub/x/": "",
+			},
+			wd: "m", dir: "dep/sub/x",
+			want: "example.com/sub/x",
+		},
+		{
+			name: "go.work replace",
+			files: map[string]string{
+				"go.work":  "go 1.22\n\nuse ./m\n\nreplace example.com/dep => ./dep\n",
+				"m/go.mod": "module example.com/m\n",
+				"dep/x/":   "",
+			},
+			wd: "m", dir: "dep/x",
+			want: "example.com/dep/x",
+		},
+		{
+			name: "module version replace",
+			files: map[string]string{
+				"m/go.mod":   "module example.com/m\n\nreplace example.com/dep => example.com/fork v1.0.0\n",
+				"dep/go.mod": "module example.com/dep\n",
+				"dep/x/":     "",
+			},
+			wd: "m", dir: "dep/x",
+			want: "example.com/dep/x",
+		},
+		{
+			name:  "module cache",
+			files: map[string]string{"cache/github.com/!foo/bar/v2@v2.1.0/api/": ""},
+			wd:    ".", dir: "cache/github.com/!foo/bar/v2@v2.1.0/api",
+			want: "github.com/Foo/bar/v2/api",
+		},
+		{
+			name:  "no module",
+			files: map[string]string{"x/": ""},
+			wd:    ".", dir: "x",
+			err: ErrNoModule,
+		},
+		{
+			name:  "invalid path",
+			files: map[string]string{"m/go.mod": "module example.com/m\n", "m/a b/": ""},
+			wd:    "m", dir: "m/a b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GOWORK", "")
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+			r, err := NewResolver(filepath.Join(dir, tt.wd))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.modCache = filepath.Join(dir, "cache")
+
+			got, err := r.ImportPath(filepath.Join(dir, filepath.FromSlash(tt.dir)))
+			switch {
+			case tt.want == "" && err == nil:
+				t.Fatalf("ImportPath = %s, want an error", got)
+			case tt.err != nil && !errors.Is(err, tt.err):
+				t.Fatalf("ImportPath error = %v, want %v", err, tt.err)
+			case tt.want != "" && err != nil:
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ImportPath = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGoWorkOff(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.work":  "go 1.22\n\nuse ./m\n\nreplace example.com/dep => ./dep\n",
+		"m/go.mod": "module example.com/m\n",
+		"dep/x/":   "",
+	})
+	t.Setenv("GOWORK", "off")
+	r, err := NewResolver(filepath.Join(dir, "m"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := r.ImportPath(filepath.Join(dir, "dep", "x")); !errors.Is(err, ErrNoModule) {
+		t.Errorf("ImportPath = %q, %v, want %v", got, err, ErrNoModule)
+	}
+}
+
+func TestCachedDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"github.com/!foo/bar@v1.2.3/api/":    "",
+		"github.com/!foo/bar/v2@v2.0.0/api/": "",
+		"example.com/m@v1.0.0-!r!c1/":        "",
+	})
+	r := &Resolver{modCache: dir}
+
+	tests := []struct {
+		pkgPath, version string
+		want             string
+		err              error
+	}{
+		{"github.com/Foo/bar/api", "v1.2.3", "github.com/!foo/bar@v1.2.3/api", nil},
+		{"github.com/Foo/bar", "v1.2.3", "github.com/!foo/bar@v1.2.3", nil},
+		{"github.com/Foo/bar/v2/api", "v2.0.0", "github.com/!foo/bar/v2@v2.0.0/api", nil},
+		{"example.com/m", "v1.0.0-RC1", "example.com/m@v1.0.0-!r!c1", nil},
+		// v2 of a path without /v2 isn't the same module
+		{"github.com/Foo/bar/api", "v2.0.0", "", ErrNotCached},
+		{"github.com/Foo/bar/api", "v1.2.4", "", ErrNotCached},
+	}
+	for _, tt := range tests {
+		got, err := r.CachedDir(tt.pkgPath, tt.version)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("CachedDir(%s, %s) = %s, %v, want %v", tt.pkgPath, tt.version, got, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("CachedDir(%s, %s): %v", tt.pkgPath, tt.version, err)
+			continue
+		}
+		if want := filepath.Join(dir, filepath.FromSlash(tt.want)); got != want {
+			t.Errorf("CachedDir(%s, %s) = %s, want %s", tt.pkgPath, tt.version, got, want)
+		}
+	}
+}
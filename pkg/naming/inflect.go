@@ -0,0 +1,148 @@
+package naming
+
+import (
+	"strings"
+	"unicode"
+)
+
+var (
+	irregulars = map[string]string{
+		"person": "people",
+		"child":  "children",
+		"man":    "men",
+		"woman":  "women",
+		"mouse":  "mice",
+		"goose":  "geese",
+		"foot":   "feet",
+		"tooth":  "teeth",
+		"datum":  "data",
+		"index":  "indices",
+		// most words ending in f or fe just take an s
+		"calf":  "calves",
+		"elf":   "elves",
+		"half":  "halves",
+		"knife": "knives",
+		"leaf":  "leaves",
+		"life":  "lives",
+		"loaf":  "loaves",
+		"self":  "selves",
+		"shelf": "shelves",
+		"thief": "thieves",
+		"wife":  "wives",
+		"wolf":  "wolves",
+	}
+	uncountables = map[string]bool{
+		"data":      true,
+		"metadata":  true,
+		"info":      true,
+		"equipment": true,
+		"news":      true,
+		"series":    true,
+		"species":   true,
+	}
+	singulars = func() map[string]string {
+		m := make(map[string]string, len(irregulars))
+		for k, v := range irregulars {
+			m[v] = k
+		}
+		return m
+	}()
+)
+
+// Plural returns the plural form of the last word of name, keeping the
+// rest of name untouched: "UserProfile" becomes "UserProfiles".
+func Plural(name string) string {
+	return inflectLast(name, plural)
+}
+
+// Singular is the inverse of Plural.
+func Singular(name string) string {
+	return inflectLast(name, singular)
+}
+
+func inflectLast(name string, fn func(string) string) string {
+	words := Words(name)
+	if len(words) == 0 {
+		return name
+	}
+
+	last := words[len(words)-1]
+	i := strings.LastIndex(name, last)
+	return name[:i] + matchCase(last, fn(strings.ToLower(last))) + name[i+len(last):]
+}
+
+func plural(word string) string {
+	if uncountables[word] {
+		return word
+	}
+	if p, ok := irregulars[word]; ok {
+		return p
+	}
+
+	switch {
+	case hasAnySuffix(word, "s", "x", "z", "ch", "sh"):
+		return word + "es"
+	case strings.HasSuffix(word, "y") && !afterVowel(word, 1):
+		return word[:len(word)-1] + "ies"
+	default:
+		return word + "s"
+	}
+}
+
+func singular(word string) string {
+	if uncountables[word] {
+		return word
+	}
+	if s, ok := singulars[word]; ok {
+		return s
+	}
+
+	switch {
+	case hasAnySuffix(word, "us", "ias"):
+		// status and alias are singular already
+		return word
+	case strings.HasSuffix(word, "ies") && len(word) > 3:
+		return word[:len(word)-3] + "y"
+	case hasAnySuffix(word, "sses", "xes", "zes", "ches", "shes", "iases", "nuses", "ruses", "tuses"):
+		// aliases and statuses, but not cases or causes
+		return word[:len(word)-2]
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss"):
+		return word[:len(word)-1]
+	default:
+		return word
+	}
+}
+
+func hasAnySuffix(word string, suffixes ...string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(word, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// afterVowel reports whether the rune n places from the end follows a vowel.
+func afterVowel(word string, n int) bool {
+	if len(word) <= n {
+		return false
+	}
+	return strings.ContainsRune("aeiou", rune(word[len(word)-n-1]))
+}
+
+// matchCase gives word the casing of like: upper, title or lower. The
+// plural s of an initialism stays lower case, as in IDs.
+func matchCase(like, word string) string {
+	switch {
+	case Initialisms[strings.TrimSuffix(like, "s")]:
+		return title(word)
+	case strings.ToUpper(like) == like:
+		return strings.ToUpper(word)
+	case unicode.IsUpper([]rune(like)[0]):
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		return string(runes)
+	default:
+		return word
+	}
+}
@@ -0,0 +1,48 @@
+package naming
+
+import "testing"
+
+func TestInflect(t *testing.T) {
+	tests := []struct {
+		singular, plural string
+	}{
+		{"User", "Users"},
+		{"UserProfile", "UserProfiles"},
+		{"Category", "Categories"},
+		{"Day", "Days"},
+		{"Box", "Boxes"},
+		{"Address", "Addresses"},
+		{"Alias", "Aliases"},
+		{"Bonus", "Bonuses"},
+		{"Status", "Statuses"},
+		{"UserStatus", "UserStatuses"},
+		{"Archive", "Archives"},
+		{"Objective", "Objectives"},
+		{"Proof", "Proofs"},
+		{"Chief", "Chiefs"},
+		{"Leaf", "Leaves"},
+		{"Knife", "Knives"},
+		{"BookShelf", "BookShelves"},
+		{"Case", "Cases"},
+		{"Cause", "Causes"},
+		{"Person", "People"},
+		{"Metadata", "Metadata"},
+		{"ID", "IDs"},
+		{"UserURL", "UserURLs"},
+		{"USER", "USERS"},
+	}
+	for _, tt := range tests {
+		if got := Plural(tt.singular); got != tt.plural {
+			t.Errorf("Plural(%q) = %q, want %q", tt.singular, got, tt.plural)
+		}
+		if got := Singular(tt.plural); got != tt.singular {
+			t.Errorf("Singular(%q) = %q, want %q", tt.plural, got, tt.singular)
+		}
+	}
+
+	for _, word := range []string{"Status", "Bonus", "Alias", "Address", "User"} {
+		if got := Singular(word); got != word {
+			t.Errorf("Singular(%q) = %q, want it unchanged", word, got)
+		}
+	}
+}
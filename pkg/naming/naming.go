@@ -0,0 +1,111 @@
+// Package naming converts identifiers between casing styles and between
+// singular and plural forms.
+package naming
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Initialisms are kept upper case by Pascal and Camel, as golint expects.
+var Initialisms = map[string]bool{
+	"ACL": true, "API": true, "ASCII": true, "CPU": true, "CSS": true,
+	"DNS": true, "EOF": true, "GRPC": true, "GUID": true, "HTML": true,
+	"HTTP": true, "HTTPS": true, "ID": true, "IP": true, "JSON": true,
+	"LHS": true, "QPS": true, "RAM": true, "RHS": true, "RPC": true,
+	"SLA": true, "SMTP": true, "SQL": true, "SSH": true, "TCP": true,
+	"TLS": true, "TTL": true, "UDP": true, "UI": true, "UID": true,
+	"UUID": true, "URI": true, "URL": true, "UTF8": true, "VM": true,
+	"XML": true, "XMPP": true, "XSRF": true, "XSS": true,
+}
+
+// Words splits name on separators and case changes, so "getHTTPServer_v2"
+// becomes [get HTTP Server v2]. A lone s after capitals is the plural of an
+// acronym, so "userIDs" becomes [user IDs].
+func Words(name string) []string {
+	var (
+		words []string
+		word  []rune
+	)
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = word[:0]
+		}
+	}
+
+	runes := []rune(name)
+	for i, r := range runes {
+		if r == '_' || r == '-' || r == '.' || unicode.IsSpace(r) {
+			flush()
+			continue
+		}
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1]) && !pluralS(runes, i+1)
+			// a new word starts at lower->Upper, and at the last capital of
+			// an acronym followed by lower case, as in HTTPServer
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				flush()
+			}
+		}
+		word = append(word, r)
+	}
+	flush()
+
+	return words
+}
+
+// Pascal returns name as PascalCase, keeping initialisms upper case.
+func Pascal(name string) string {
+	var b strings.Builder
+	for _, w := range Words(name) {
+		b.WriteString(title(w))
+	}
+	return b.String()
+}
+
+// Camel returns name as camelCase, keeping initialisms upper case after the
+// first word.
+func Camel(name string) string {
+	words := Words(name)
+	if len(words) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.ToLower(words[0]))
+	for _, w := range words[1:] {
+		b.WriteString(title(w))
+	}
+	return b.String()
+}
+
+// Snake returns name as snake_case.
+func Snake(name string) string {
+	return strings.ToLower(strings.Join(Words(name), "_"))
+}
+
+// Kebab returns name as kebab-case.
+func Kebab(name string) string {
+	return strings.ToLower(strings.Join(Words(name), "-"))
+}
+
+// pluralS reports whether runes[i] is an s ending a word.
+func pluralS(runes []rune, i int) bool {
+	return runes[i] == 's' && (i+1 == len(runes) || !unicode.IsLower(runes[i+1]))
+}
+
+func title(word string) string {
+	if upper := strings.ToUpper(word); Initialisms[upper] {
+		return upper
+	}
+	if lower := strings.ToLower(word); strings.HasSuffix(lower, "s") {
+		if upper := strings.ToUpper(lower[:len(lower)-1]); Initialisms[upper] {
+			return upper + "s"
+		}
+	}
+	runes := []rune(strings.ToLower(word))
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
@@ -0,0 +1,52 @@
+package naming
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"getHTTPServer_v2", []string{"get", "HTTP", "Server", "v2"}},
+		{"user-profile id", []string{"user", "profile", "id"}},
+		{"userIDs", []string{"user", "IDs"}},
+		{"IDs", []string{"IDs"}},
+		{"listURLsByHost", []string{"list", "URLs", "By", "Host"}},
+		{"APIServer", []string{"API", "Server"}},
+	}
+	for _, tt := range tests {
+		if got := Words(tt.name); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Words(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCase(t *testing.T) {
+	tests := []struct {
+		name                        string
+		pascal, camel, snake, kebab string
+	}{
+		{"user_profile", "UserProfile", "userProfile", "user_profile", "user-profile"},
+		{"getHTTPServer", "GetHTTPServer", "getHTTPServer", "get_http_server", "get-http-server"},
+		{"userIDs", "UserIDs", "userIDs", "user_ids", "user-ids"},
+		{"IDs", "IDs", "ids", "ids", "ids"},
+		{"user_id", "UserID", "userID", "user_id", "user-id"},
+	}
+	for _, tt := range tests {
+		if got := Pascal(tt.name); got != tt.pascal {
+			t.Errorf("Pascal(%q) = %q, want %q", tt.name, got, tt.pascal)
+		}
+		if got := Camel(tt.name); got != tt.camel {
+			t.Errorf("Camel(%q) = %q, want %q", tt.name, got, tt.camel)
+		}
+		if got := Snake(tt.name); got != tt.snake {
+			t.Errorf("Snake(%q) = %q, want %q", tt.name, got, tt.snake)
+		}
+		if got := Kebab(tt.name); got != tt.kebab {
+			t.Errorf("Kebab(%q) = %q, want %q", tt.name, got, tt.kebab)
+		}
+	}
+}
@@ -34,6 +34,7 @@ func Lookup(word string) string {
 
 func TrimFileName(name string) (out string) {
 	out = name
+	out = strings.TrimSuffix(out, ".proto")
 	out = strings.TrimSuffix(out, ".go")
 	out = strings.TrimSuffix(out, ".pb")
 	out = strings.TrimSuffix(out, ".gw")
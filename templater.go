@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -10,7 +11,9 @@ import (
 func main() {
 	args := os.Args[1:]
 	if err := cli.Run(args); err != nil {
-		fmt.Fprintln(os.Stderr, err)
+		if !errors.Is(err, cli.ExitFailure) {
+			fmt.Fprintln(os.Stderr, err)
+		}
 		os.Exit(1)
 	}
 }
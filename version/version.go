@@ -20,3 +20,13 @@ var GitCommit = func() (commit string) {
 	}
 	return
 }()
+
+// Version is the module version gotem was built at, devel when the build
+// doesn't record one.
+var Version = func() string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok || bi.Main.Version == "" || bi.Main.Version == "(devel)" {
+		return "devel"
+	}
+	return bi.Main.Version
+}()